@@ -1,89 +1,359 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	corev1 "k8s.io/api/core/v1"
+	kubeclient "k8s.io/client-go/kubernetes"
 
+	"kube-logger-go/internal/archive"
+	"kube-logger-go/internal/cache"
 	"kube-logger-go/internal/config"
 	"kube-logger-go/internal/kubernetes"
 	"kube-logger-go/internal/logs"
+	"kube-logger-go/internal/output"
 	"kube-logger-go/internal/pagination"
 	"kube-logger-go/internal/types"
 )
 
 func main() {
 	cfg := config.ParseFlags()
+	connect := func() (kubeclient.Interface, error) { return kubernetes.NewClient(cfg) }
+	if cfg.Serve {
+		os.Exit(serve(cfg, connect, os.Stderr))
+	}
+	os.Exit(run(cfg, connect, os.Stdout, os.Stderr))
+}
+
+// run serves one invocation and returns the process exit code. The client is only connected
+// once the arguments are valid, so mistakes in them are reported without a cluster.
+func run(cfg types.Config, connect func() (kubeclient.Interface, error), stdout, stderr io.Writer) int {
+	processor, err := validate(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if cfg.Server != "" {
+		return remote(cfg, stdout, stderr)
+	}
+
+	// A token from another query, or a corrupted one, would quietly replay the window
+	codec := pagination.NewCodec(cfg.TokenKey, cfg)
+	cursors, err := codec.Decode(cfg.NextPageToken)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: invalid next-page-token: %v\n", err)
+		return 1
+	}
+
+	// Create Kubernetes client
+	clientset, err := connect()
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to create Kubernetes client: %v\n", err)
+		return 1
+	}
+
+	source := logs.NewKubernetesSource(clientset)
+	if cfg.Follow {
+		return follow(source, processor, cfg, stdout, stderr)
+	}
+
+	sources, logCache, err := openSources(cfg, source)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to %v\n", err)
+		return 1
+	}
+	if logCache != nil && cfg.Verbose {
+		defer func() {
+			stats := logCache.Stats()
+			fmt.Fprintf(stderr, "Cache: %d hits, %d misses\n", stats.Hits, stats.Misses)
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	// Get logs concurrently from all pods, live or archived
+	fetcher := logs.NewFetcher(processor, sources...)
+	if cfg.Histogram > 0 {
+		return histogram(ctx, fetcher, cfg, stdout, stderr)
+	}
+
+	response, fetched, err := readPage(ctx, fetcher, cfg, codec, cursors)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to get pods: %v\n", err)
+		return 1
+	}
+
+	if err := output.WritePage(stdout, stderr, response, cfg.Output, output.UseColor(cfg.Color, stdout)); err != nil {
+		fmt.Fprintf(stderr, "Failed to write the response: %v\n", err)
+		return 1
+	}
+
+	if cfg.Strict && fetched.AllFailed() {
+		fmt.Fprintf(stderr, "Error: no container log could be read\n")
+		return 1
+	}
+	return 0
+}
+
+// validate checks a query and builds the processor of its lines
+func validate(cfg types.Config) (*logs.Processor, error) {
+	if len(cfg.Namespaces) == 0 && !cfg.AllNamespaces {
+		return nil, fmt.Errorf("namespace is required, or all-namespaces")
+	}
 
-	// Validate required parameters
-	if cfg.Namespace == "" {
-		fmt.Fprintf(os.Stderr, "Error: namespace is required\n")
-		os.Exit(1)
+	if len(cfg.Namespaces) > 0 && cfg.AllNamespaces {
+		return nil, fmt.Errorf("namespace and all-namespaces cannot be combined")
 	}
 
 	for flagName, bound := range map[string]string{"start-time": cfg.StartTime, "end-time": cfg.EndTime} {
 		if bound != "" && !logs.ValidTimestamp(bound) {
-			fmt.Fprintf(os.Stderr, "Error: %s must be RFC3339, e.g. 2026-08-17T23:59:59Z (got %q)\n", flagName, bound)
-			os.Exit(1)
+			return nil, fmt.Errorf("%s must be RFC3339, e.g. 2026-08-17T23:59:59Z (got %q)", flagName, bound)
 		}
 	}
 
-	// Create Kubernetes client
-	clientset, err := kubernetes.NewClient()
+	filter, err := logs.CompileFilter(cfg.FilterPattern, cfg.IgnoreCase)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %v", cfg.FilterPattern, err)
+	}
+	joiner, err := logs.NewJoiner(cfg.Multiline, cfg.MultilineStart)
+	if err != nil {
+		return nil, err
+	}
+	redactor, err := logs.NewRedactor(cfg.Redact, cfg.RedactRules)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Direction != types.DirectionForward && cfg.Direction != types.DirectionBackward {
+		return nil, fmt.Errorf("direction must be forward or backward (got %q)", cfg.Direction)
+	}
+
+	if cfg.Follow && cfg.Direction == types.DirectionBackward {
+		return nil, fmt.Errorf("follow only reads forward")
+	}
+
+	if cfg.Follow && (cfg.IncludeEvents || cfg.Previous != types.PreviousNever) {
+		return nil, fmt.Errorf("follow only streams the running containers; it reads neither events nor previous instances")
+	}
+
+	if cfg.Follow && cfg.IdleTimeout <= 0 {
+		return nil, fmt.Errorf("idle-timeout must be positive, e.g. 10m (got %s)", cfg.IdleTimeout)
+	}
+
+	if cfg.Parallelism <= 0 {
+		return nil, fmt.Errorf("parallelism must be positive (got %d)", cfg.Parallelism)
+	}
+
+	if cfg.PodTimeout <= 0 || cfg.Timeout <= 0 {
+		return nil, fmt.Errorf("pod-timeout and timeout must be positive, e.g. 10s (got %s and %s)", cfg.PodTimeout, cfg.Timeout)
+	}
+
+	if cfg.CacheDir != "" && (cfg.CacheTTL <= 0 || cfg.CacheMaxBytes <= 0) {
+		return nil, fmt.Errorf("cache-ttl and cache-max-bytes must be positive (got %s and %d)", cfg.CacheTTL, cfg.CacheMaxBytes)
+	}
+
+	if !output.Valid(cfg.Output) {
+		return nil, fmt.Errorf("output must be json, ndjson, text or csv (got %q)", cfg.Output)
+	}
+
+	if cfg.Color != output.ColorAuto && cfg.Color != output.ColorAlways && cfg.Color != output.ColorNever {
+		return nil, fmt.Errorf("color must be auto, always or never (got %q)", cfg.Color)
+	}
+
+	if cfg.Histogram < 0 {
+		return nil, fmt.Errorf("histogram bucket must be positive, e.g. 1m (got %s)", cfg.Histogram)
+	}
+
+	if cfg.HistogramBy != "" && cfg.HistogramBy != types.HistogramByPod && cfg.HistogramBy != types.HistogramByLevel {
+		return nil, fmt.Errorf("histogram-by must be pod or level (got %q)", cfg.HistogramBy)
+	}
+
+	if cfg.HistogramBy != "" && cfg.Histogram == 0 {
+		return nil, fmt.Errorf("histogram-by needs --histogram")
+	}
+
+	if cfg.Histogram > 0 && cfg.StartTime != "" && cfg.EndTime != "" {
+		start, _ := time.Parse(time.RFC3339Nano, cfg.StartTime)
+		end, _ := time.Parse(time.RFC3339Nano, cfg.EndTime)
+		if buckets := end.Sub(start) / cfg.Histogram; buckets > types.MaxHistogramBuckets {
+			return nil, fmt.Errorf("histogram would have %d buckets, more than %d; use longer ones", buckets, types.MaxHistogramBuckets)
+		}
+	}
+
+	if cfg.Histogram > 0 && cfg.Output != output.JSON {
+		return nil, fmt.Errorf("histogram is only written as json")
+	}
+
+	if cfg.Histogram > 0 && (cfg.Follow || cfg.NextPageToken != "") {
+		return nil, fmt.Errorf("histogram counts the whole window at once; it neither follows nor pages")
+	}
+
+	if cfg.Server != "" && (cfg.Follow || cfg.Histogram > 0) {
+		return nil, fmt.Errorf("a server only answers queries of a page; it neither follows nor counts")
+	}
+
+	if cfg.Server != "" && cfg.Strict {
+		return nil, fmt.Errorf("strict needs the reads of every container, which a server does not answer")
+	}
+
+	// The server reads with its own rules and limits; a query asking for others would
+	// quietly get the server's
+	if cfg.Server != "" && cfg.RedactRules != "" {
+		return nil, fmt.Errorf("redact-rules are read by the server; start it with them")
+	}
+
+	if cfg.Server != "" && (cfg.Parallelism != types.DefaultParallelism || cfg.Timeout != types.DefaultTimeout || cfg.PodTimeout != types.DefaultPodTimeout) {
+		return nil, fmt.Errorf("parallelism, timeout and pod-timeout are the server's; start it with them")
+	}
+
+	if cfg.Kubeconfig != "" {
+		if _, err := os.Stat(cfg.Kubeconfig); err != nil {
+			return nil, fmt.Errorf("kubeconfig cannot be read: %v", err)
+		}
+	}
+
+	if len(cfg.AsGroups) > 0 && cfg.As == "" {
+		return nil, fmt.Errorf("as-group needs a user to impersonate with --as")
+	}
+
+	if cfg.QPS < 0 || cfg.Burst < 0 {
+		return nil, fmt.Errorf("qps and burst cannot be negative (got %g and %d)", cfg.QPS, cfg.Burst)
+	}
+
+	return logs.NewProcessor(filter, joiner, redactor), nil
+}
+
+// openSources adds the archive and the cache a query asks for to the live source. The cache
+// is nil without one.
+func openSources(cfg types.Config, source logs.LogSource) ([]logs.LogSource, *cache.Cache, error) {
+	sources := []logs.LogSource{source}
+	if cfg.Archive != "" {
+		store, err := archive.Open(context.Background(), cfg.Archive)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open archive: %v", err)
+		}
+		sources = append(sources, logs.NewArchiveSource(store))
+	}
+
+	if cfg.CacheDir == "" {
+		return sources, nil, nil
+	}
+	logCache, err := cache.Open(cfg.CacheDir, cfg.CacheTTL, cfg.CacheMaxBytes)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create Kubernetes client: %v\n", err)
-		os.Exit(1)
-	}
-
-	// Get all pods or a specific pod
-	var pods []corev1.Pod
-    if cfg.InstanceID != "" {
-    	pod, err := kubernetes.GetPod(clientset, cfg.Namespace, cfg.InstanceID)
-    	if err != nil {
-    		fmt.Fprintf(os.Stderr, "Failed to get pod: %v\n", err)
-    		os.Exit(1)
-    	}
-    	if pod != nil {
-    		pods = []corev1.Pod{*pod}
-    	} else {
-    		pods = []corev1.Pod{}
-    	}
-    } else {
-    	var err error
-    	pods, err = kubernetes.GetPods(clientset, cfg)
-    	if err != nil {
-    		fmt.Fprintf(os.Stderr, "Failed to get pods: %v\n", err)
-    		os.Exit(1)
-    	}
-    }
-
-	if len(pods) == 0 {
-		outputEmptyResponse()
-		return
-	}
-
-	// Get logs concurrently from all pods
-	fetcher := logs.NewFetcher(clientset)
-	allLogs := fetcher.FetchConcurrently(pods, cfg)
-
-	allLogs, token := pagination.Page(allLogs, cfg.Limit, pagination.DecodeToken(cfg.NextPageToken))
+		return nil, nil, fmt.Errorf("open cache: %v", err)
+	}
+	for i, source := range sources {
+		sources[i] = logs.NewCachedSource(source, logCache)
+	}
+	return sources, logCache, nil
+}
+
+// readPage reads the page of a query that starts at cursors
+func readPage(ctx context.Context, fetcher *logs.Fetcher, cfg types.Config, codec *pagination.Codec, cursors map[string]string) (types.Response, logs.FetchResult, error) {
+	fetched, err := fetcher.FetchConcurrently(ctx, cursors, cfg)
+	if err != nil {
+		return types.Response{}, fetched, err
+	}
+
+	// Streams that read lines without keeping any still moved on
+	for key, position := range fetched.Progress {
+		cursors[key] = position
+	}
+
+	allLogs, token := pagination.Page(fetched.Entries, cfg.Limit, cursors, pagination.TokenDirection(cursors, cfg.Direction), codec)
+	if token == "" && fetched.Unfinished() {
+		// Timeouts or byte limits emptied the page before the end; the next one goes on from
+		// the cursors.
+		token = codec.Encode(cursors)
+	}
 
 	response := types.Response{
 		Results:       allLogs,
 		NextPageToken: token,
+		TimedOut:      fetched.TimedOut,
+		Warnings:      fetched.Warnings,
+	}
+	for _, entry := range allLogs {
+		response.Redactions += entry.Redactions
+	}
+	return response, fetched, nil
+}
+
+// histogram writes the counts of the window per bucket. It returns the process exit code.
+func histogram(ctx context.Context, fetcher *logs.Fetcher, cfg types.Config, stdout, stderr io.Writer) int {
+	counted, err := fetcher.Histogram(ctx, cfg)
+	if errors.Is(err, logs.ErrTooManyBuckets) {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to get pods: %v\n", err)
+		return 1
 	}
 
-	output, _ := json.Marshal(response)
-	fmt.Println(string(output))
+	response := types.HistogramResponse{
+		Buckets:  counted.Buckets,
+		TimedOut: counted.TimedOut,
+		Warnings: counted.Warnings,
+	}
+
+	encoded, _ := json.Marshal(response)
+	fmt.Fprintln(stdout, string(encoded))
+
+	if cfg.Strict && counted.AllFailed() {
+		fmt.Fprintf(stderr, "Error: no container log could be read\n")
+		return 1
+	}
+	return 0
 }
 
-func outputEmptyResponse() {
-	response := types.Response{
-		Results:       []types.LogEntry{},
-		NextPageToken: "",
+// follow writes one entry per line as logs are written, until it is interrupted or no line
+// arrives for the idle timeout. It returns the process exit code.
+func follow(source *logs.KubernetesSource, processor *logs.Processor, cfg types.Config, stdout, stderr io.Writer) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	entries := make(chan types.LogEntry, 100)
+	warnings := make(chan types.Warning, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- source.Follow(ctx, processor, cfg, entries, warnings)
+	}()
+
+	writer := output.NewEntryWriter(stdout, cfg.Output, output.UseColor(cfg.Color, stdout))
+	idle := time.NewTimer(cfg.IdleTimeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case entry := <-entries:
+			if err := writer.Write(entry); err != nil {
+				// The reader went away, e.g. the end of a pipe closed.
+				cancel()
+			}
+			idle.Reset(cfg.IdleTimeout)
+		case warning := <-warnings:
+			output.WriteWarning(stderr, warning)
+		case <-idle.C:
+			cancel()
+		case err := <-done:
+			if err != nil {
+				fmt.Fprintf(stderr, "Failed to follow logs: %v\n", err)
+				return 1
+			}
+			return 0
+		}
 	}
-	output, _ := json.Marshal(response)
-	fmt.Println(string(output))
-}
\ No newline at end of file
+}
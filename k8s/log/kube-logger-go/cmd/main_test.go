@@ -0,0 +1,655 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	kubeclient "k8s.io/client-go/kubernetes"
+
+	"kube-logger-go/internal/kubernetes/fake"
+	"kube-logger-go/internal/output"
+	"kube-logger-go/internal/types"
+)
+
+// labels are the ones nullplatform puts on the pods of application 1, scope 2
+var labels = map[string]string{"nullplatform": "true", "application_id": "1", "scope_id": "2"}
+
+// queryConfig is what the flags parse to for a query of the scope's window
+func queryConfig(limit int) types.Config {
+	return types.Config{
+		Namespaces:    []string{"ns"},
+		ApplicationID: "1",
+		ScopeID:       "2",
+		Limit:         limit,
+		StartTime:     "2026-08-17T10:00:00Z",
+		EndTime:       "2026-08-17T10:00:59Z",
+		Direction:     types.DirectionForward,
+		Containers:    []string{types.DefaultContainerName},
+		Parallelism:   types.DefaultParallelism,
+		PodTimeout:    types.DefaultPodTimeout,
+		Timeout:       types.DefaultTimeout,
+		Output:        output.JSON,
+		Color:         output.ColorNever,
+	}
+}
+
+// query runs one invocation against a cluster and decodes its response
+func query(t *testing.T, cluster *fake.Cluster, cfg types.Config) types.Response {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	connect := func() (kubeclient.Interface, error) { return cluster, nil }
+	if code := run(cfg, connect, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+
+	var response types.Response
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		t.Fatalf("output is not a response: %v\n%s", err, stdout.String())
+	}
+	return response
+}
+
+// pageThrough follows the tokens of a query to its end and returns every message delivered.
+// Only the token carries the direction from the second page on, as with k8s/log/log.
+func pageThrough(t *testing.T, cluster *fake.Cluster, cfg types.Config) []string {
+	t.Helper()
+
+	var delivered []string
+	for page := 1; ; page++ {
+		if page > 10 {
+			t.Fatalf("pagination did not terminate after 10 pages, delivered: %q", delivered)
+		}
+
+		response := query(t, cluster, cfg)
+		for _, entry := range response.Results {
+			delivered = append(delivered, entry.Message)
+		}
+
+		if response.NextPageToken == "" {
+			return delivered
+		}
+		cfg.NextPageToken = response.NextPageToken
+		cfg.Direction = types.DirectionForward
+	}
+}
+
+// windowCluster has two pods whose lines interleave, and a third with nothing in the window.
+func windowCluster() *fake.Cluster {
+	cluster := fake.NewCluster(
+		fake.Pod("ns", "app-a", labels, "application"),
+		fake.Pod("ns", "app-b", labels, "application"),
+		fake.Pod("ns", "app-c", labels, "application"),
+	)
+	cluster.AddLogs("ns", "app-a", "application", false,
+		"2026-08-17T10:00:01.000000000Z a first",
+		"2026-08-17T10:00:03.000000000Z a second",
+		"2026-08-17T10:00:05.000000000Z a third",
+	)
+	cluster.AddLogs("ns", "app-b", "application", false,
+		"2026-08-17T10:00:02.000000000Z b first",
+		"2026-08-17T10:00:04.000000000Z b second",
+	)
+	cluster.AddLogs("ns", "app-c", "application", false,
+		"2026-08-18T09:00:00.000000000Z c past the window",
+	)
+	return cluster
+}
+
+// A pod that loses its cursor restarts from start_time, and with more than one pod the
+// pages take turns evicting each other and never reach the end of the window. Pod c has no
+// lines in the window at all, so it never earns a cursor and is re-read on every page.
+func TestPaginationDeliversEveryLineInTheWindowExactlyOnce(t *testing.T) {
+	delivered := pageThrough(t, windowCluster(), queryConfig(2))
+
+	want := []string{"a first", "b first", "a second", "b second", "a third"}
+	if !slices.Equal(delivered, want) {
+		t.Errorf("expected %q, got %q", want, delivered)
+	}
+}
+
+// Paging backward reads each pod up to its cursor, so a pod whose lines are all older than
+// the first pages must keep no cursor until it contributes, and every page runs newest first.
+func TestBackwardPaginationDeliversEveryLineInTheWindowExactlyOnce(t *testing.T) {
+	cfg := queryConfig(2)
+	cfg.Direction = types.DirectionBackward
+
+	delivered := pageThrough(t, windowCluster(), cfg)
+
+	want := []string{"a third", "b second", "a second", "b first", "a first"}
+	if !slices.Equal(delivered, want) {
+		t.Errorf("expected %q, got %q", want, delivered)
+	}
+}
+
+// A joined entry starts at its first line but must resume after its last, or the rest of
+// the stack trace comes back on the next page as entries of its own.
+func TestPaginationDeliversJoinedEntriesExactlyOnce(t *testing.T) {
+	cluster := fake.NewCluster(
+		fake.Pod("ns", "app-a", labels, "application"),
+		fake.Pod("ns", "app-b", labels, "application"),
+	)
+	cluster.AddLogs("ns", "app-a", "application", false,
+		"2026-08-17T10:00:01.000000000Z a first",
+		"2026-08-17T10:00:02.000000000Z a failed",
+		"2026-08-17T10:00:02.000000100Z java.lang.IllegalStateException: boom",
+		"2026-08-17T10:00:02.000000200Z \tat com.example.Service.run(Service.java:42)",
+		"2026-08-17T10:00:04.000000000Z a last",
+	)
+	cluster.AddLogs("ns", "app-b", "application", false,
+		"2026-08-17T10:00:03.000000000Z b only",
+	)
+	cfg := queryConfig(1)
+	cfg.Multiline = true
+
+	delivered := pageThrough(t, cluster, cfg)
+
+	want := []string{
+		"a first",
+		"a failed\njava.lang.IllegalStateException: boom\n\tat com.example.Service.run(Service.java:42)",
+		"b only",
+		"a last",
+	}
+	if !slices.Equal(delivered, want) {
+		t.Errorf("expected %q, got %q", want, delivered)
+	}
+}
+
+// A burst writes several lines within the same nanosecond. A page cut between them must
+// resume at the next line of the burst, neither repeating the ones before nor skipping the rest.
+func TestPaginationDeliversLinesSharingATimestampExactlyOnce(t *testing.T) {
+	cluster := fake.NewCluster(fake.Pod("ns", "app-a", labels, "application"))
+	cluster.AddLogs("ns", "app-a", "application", false,
+		"2026-08-17T10:00:01.000000000Z a burst 1",
+		"2026-08-17T10:00:01.000000000Z a burst 2",
+		"2026-08-17T10:00:01.000000000Z a burst 3",
+		"2026-08-17T10:00:01.000000000Z a burst 4",
+		"2026-08-17T10:00:02.000000000Z a after",
+	)
+
+	for _, direction := range []string{types.DirectionForward, types.DirectionBackward} {
+		cfg := queryConfig(3)
+		cfg.Direction = direction
+
+		delivered := pageThrough(t, cluster, cfg)
+
+		want := []string{"a burst 1", "a burst 2", "a burst 3", "a burst 4", "a after"}
+		if direction == types.DirectionBackward {
+			slices.Reverse(want)
+		}
+		if !slices.Equal(delivered, want) {
+			t.Errorf("%s: expected %q, got %q", direction, want, delivered)
+		}
+	}
+}
+
+// The API cuts a stream at its byte limit, possibly mid-line. A pod with long lines is cut
+// well before a pod with short ones, and the page must neither deliver the half line nor the
+// lines of the other pod written after the cut, which would come before the rest of the first.
+func TestPaginationDeliversPodsCutByTheirByteLimitInTimeOrder(t *testing.T) {
+	cluster := fake.NewCluster(
+		fake.Pod("ns", "app-a", labels, "application"),
+		fake.Pod("ns", "app-b", labels, "application"),
+	)
+	long := strings.Repeat("x", 10000)
+	var want []string
+	for second := range 40 {
+		a := fmt.Sprintf("a %02d %s", second, long)
+		b := fmt.Sprintf("b %02d", second)
+		cluster.AddLogs("ns", "app-a", "application", false, fmt.Sprintf("2026-08-17T10:00:%02d.100000000Z %s", second, a))
+		cluster.AddLogs("ns", "app-b", "application", false, fmt.Sprintf("2026-08-17T10:00:%02d.200000000Z %s", second, b))
+		want = append(want, a, b)
+	}
+
+	delivered := pageThrough(t, cluster, queryConfig(20))
+
+	if !slices.Equal(delivered, want) {
+		t.Errorf("expected %d lines in time order, got %d: %q", len(want), len(delivered), delivered)
+	}
+}
+
+// A filter that matches nothing within the largest byte limit must not end pagination before
+// the lines it would match further on.
+func TestPaginationReadsPastLinesTheFilterDrops(t *testing.T) {
+	cluster := fake.NewCluster(fake.Pod("ns", "app-a", labels, "application"))
+	noise := strings.Repeat("x", 1000)
+	for second := range 50 {
+		for millisecond := range 120 {
+			cluster.AddLogs("ns", "app-a", "application", false, fmt.Sprintf("2026-08-17T10:00:%02d.%03d000000Z noise %s", second, millisecond, noise))
+		}
+	}
+	cluster.AddLogs("ns", "app-a", "application", false, "2026-08-17T10:00:55.000000000Z a match")
+	cfg := queryConfig(10)
+	cfg.FilterPattern = "match"
+
+	delivered := pageThrough(t, cluster, cfg)
+
+	if !slices.Equal(delivered, []string{"a match"}) {
+		t.Errorf("expected the match after the noise, got %q", delivered)
+	}
+}
+
+// Redaction comes before the filter, so a filter cannot find what it replaced.
+func TestRedactionHidesMessagesFromTheFilterAndIsCounted(t *testing.T) {
+	cluster := fake.NewCluster(fake.Pod("ns", "app-a", labels, "application"))
+	cluster.AddLogs("ns", "app-a", "application", false,
+		"2026-08-17T10:00:01.000000000Z login by jane@example.com",
+		"2026-08-17T10:00:02.000000000Z login by ops@example.com from jane@example.com",
+	)
+	cfg := queryConfig(10)
+	cfg.Redact = true
+
+	response := query(t, cluster, cfg)
+	if response.Redactions != 3 || response.Results[0].Message != "login by [REDACTED:email]" {
+		t.Errorf("expected 3 redacted emails, got %d in %+v", response.Redactions, response.Results)
+	}
+
+	cfg.FilterPattern = "jane"
+	if response := query(t, cluster, cfg); len(response.Results) != 0 {
+		t.Errorf("expected the filter not to match redacted emails, got %+v", response.Results)
+	}
+}
+
+// Pods of the same name in two namespaces are told apart in the output and in the token, even
+// when their UIDs collide, as the fake cluster's do.
+func TestPaginationAcrossNamespacesDeliversEveryLineExactlyOnce(t *testing.T) {
+	cluster := fake.NewCluster(
+		fake.Pod("ns", "app-a", labels, "application"),
+		fake.Pod("ns-b", "app-a", labels, "application"),
+		fake.Pod("ns-c", "app-a", labels, "application"),
+	)
+	cluster.AddLogs("ns", "app-a", "application", false,
+		"2026-08-17T10:00:01.000000000Z ns first",
+		"2026-08-17T10:00:03.000000000Z ns second",
+	)
+	cluster.AddLogs("ns-b", "app-a", "application", false,
+		"2026-08-17T10:00:02.000000000Z ns-b first",
+		"2026-08-17T10:00:04.000000000Z ns-b second",
+	)
+	cluster.AddLogs("ns-c", "app-a", "application", false,
+		"2026-08-17T10:00:05.000000000Z ns-c only",
+	)
+
+	several := queryConfig(1)
+	several.Namespaces = []string{"ns", "ns-b"}
+	if delivered, want := pageThrough(t, cluster, several), []string{"ns first", "ns-b first", "ns second", "ns-b second"}; !slices.Equal(delivered, want) {
+		t.Errorf("expected %q, got %q", want, delivered)
+	}
+
+	all := queryConfig(1)
+	all.Namespaces = nil
+	all.AllNamespaces = true
+	if delivered, want := pageThrough(t, cluster, all), []string{"ns first", "ns-b first", "ns second", "ns-b second", "ns-c only"}; !slices.Equal(delivered, want) {
+		t.Errorf("all namespaces: expected %q, got %q", want, delivered)
+	}
+
+	if pod := query(t, cluster, several).Results[0].Pod; pod.Namespace != "ns" {
+		t.Errorf("expected entries to name their namespace, got %+v", pod)
+	}
+}
+
+// The pods of a scheduled task have finished by the time their logs are read. An execution
+// is picked by its Job's name or UID.
+func TestScheduledTaskExecutionsAreReadByJob(t *testing.T) {
+	execution := func(job string) *corev1.Pod {
+		jobLabels := maps.Clone(labels)
+		jobLabels["job-name"] = job
+		jobLabels["controller-uid"] = "uid-" + job
+		pod := fake.Pod("ns", job+"-x7k2p", jobLabels, "application")
+		pod.Status.Phase = corev1.PodSucceeded
+		pod.Status.ContainerStatuses[0].State = corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0}}
+		return pod
+	}
+	cluster := fake.NewCluster(execution("job-2-3-29001"), execution("job-2-3-29002"))
+	cluster.AddLogs("ns", "job-2-3-29001-x7k2p", "application", false, "2026-08-17T10:00:01.000000000Z first run")
+	cluster.AddLogs("ns", "job-2-3-29002-x7k2p", "application", false, "2026-08-17T10:00:02.000000000Z second run")
+
+	if delivered, want := pageThrough(t, cluster, queryConfig(1)), []string{"first run", "second run"}; !slices.Equal(delivered, want) {
+		t.Errorf("expected every execution of the scope, got %q", delivered)
+	}
+
+	byName := queryConfig(10)
+	byName.JobName = "job-2-3-29002"
+	byName.IncludeMetadata = true
+	response := query(t, cluster, byName)
+	if len(response.Results) != 1 || response.Results[0].Message != "second run" || response.Results[0].Metadata.JobName != "job-2-3-29002" {
+		t.Errorf("expected only the second execution, named in its metadata, got %+v", response.Results)
+	}
+
+	byID := queryConfig(10)
+	byID.ExecutionID = "uid-job-2-3-29001"
+	if delivered := pageThrough(t, cluster, byID); !slices.Equal(delivered, []string{"first run"}) {
+		t.Errorf("expected only the first execution, got %q", delivered)
+	}
+}
+
+// Events page with the logs, in the same window and under the same token.
+func TestEventsAreInterleavedWithTheLogs(t *testing.T) {
+	cluster := fake.NewCluster(fake.Pod("ns", "app-a", labels, "application"))
+	cluster.AddLogs("ns", "app-a", "application", false,
+		"2026-08-17T10:00:01.000000000Z starting",
+		"2026-08-17T10:00:03.000000000Z listening",
+	)
+	cluster.AddEvent("ns", "app-a", "application", corev1.EventTypeWarning, "Unhealthy", "Readiness probe failed", "2026-08-17T10:00:02Z")
+	cluster.AddEvent("ns", "app-a", "", corev1.EventTypeNormal, "Scheduled", "Successfully assigned ns/app-a", "2026-08-17T09:59:00Z")
+	cfg := queryConfig(1)
+
+	if delivered := pageThrough(t, cluster, cfg); !slices.Equal(delivered, []string{"starting", "listening"}) {
+		t.Errorf("expected no events unless asked for, got %q", delivered)
+	}
+
+	cfg.IncludeEvents = true
+	delivered := pageThrough(t, cluster, cfg)
+	want := []string{"starting", `level=warn type=Warning reason=Unhealthy object=application msg="Readiness probe failed"`, "listening"}
+	if !slices.Equal(delivered, want) {
+		t.Errorf("expected the event inside the window between the lines, got %q", delivered)
+	}
+
+	cfg.Limit = 10
+	cfg.FilterPattern = "reason=Unhealthy"
+	response := query(t, cluster, cfg)
+	if len(response.Results) != 1 || response.Results[0].Source != types.SourceEvent || response.Results[0].Level != "warn" {
+		t.Errorf("expected the event as a warning from the events source, got %+v", response.Results)
+	}
+}
+
+// A second user paging the same window is served from the cache, with the same pages.
+func TestCachedPagesMatchTheLiveOnes(t *testing.T) {
+	cluster := fake.NewCluster(fake.Pod("ns", "app-a", labels, "application"))
+	// Long enough for every page but the last to be cut by its byte limit
+	for i := range 300 {
+		cluster.AddLogs("ns", "app-a", "application", false, fmt.Sprintf("2026-08-17T10:00:%02d.%03d000000Z line %d %s", i/10, i%10*100, i, strings.Repeat("x", 1000)))
+	}
+	live := pageThrough(t, cluster, queryConfig(50))
+
+	cfg := queryConfig(50)
+	cfg.CacheDir = t.TempDir()
+	cfg.CacheTTL = time.Hour
+	cfg.CacheMaxBytes = 1 << 20
+	if first := pageThrough(t, cluster, cfg); !slices.Equal(first, live) {
+		t.Fatalf("expected the pages read through the cache to match the live ones, got %q", first)
+	}
+	if second := pageThrough(t, cluster, cfg); !slices.Equal(second, live) {
+		t.Errorf("expected the pages served from the cache to match the live ones, got %q", second)
+	}
+
+	cfg.Verbose = true
+	var stdout, stderr bytes.Buffer
+	if code := run(cfg, func() (kubeclient.Interface, error) { return cluster, nil }, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "Cache: 1 hits, 0 misses") {
+		t.Errorf("expected the first page served from the cache, got %q", stderr.String())
+	}
+}
+
+// Entries name the pod and container instance they came from.
+func TestResponseIdentifiesWhereEachEntryCameFrom(t *testing.T) {
+	cluster := fake.NewCluster(fake.Pod("ns", "app-a", labels, "application", "istio-proxy"))
+	cluster.AddLogs("ns", "app-a", "istio-proxy", false, "2026-08-17T10:00:01.000000000Z upstream connect error")
+	cfg := queryConfig(10)
+	cfg.Containers = []string{types.AllContainers}
+
+	response := query(t, cluster, cfg)
+
+	want := types.LogEntry{
+		Message:   "upstream connect error",
+		DateTime:  "2026-08-17T10:00:01.000000000Z",
+		Pod:       types.PodInfo{Name: "app-a", ID: "uid-app-a"},
+		Container: "istio-proxy",
+	}
+	if len(response.Results) != 1 || !equalEntries(response.Results[0], want) {
+		t.Errorf("expected %+v, got %+v", want, response.Results)
+	}
+	if response.NextPageToken == "" {
+		t.Error("expected a token to resume after the entry")
+	}
+}
+
+func equalEntries(a, b types.LogEntry) bool {
+	return a.Message == b.Message && a.DateTime == b.DateTime && a.Pod == b.Pod && a.Container == b.Container && a.Restart == b.Restart
+}
+
+func TestQueryWithoutPodsReturnsAnEmptyPage(t *testing.T) {
+	response := query(t, fake.NewCluster(), queryConfig(10))
+
+	if response.Results == nil || len(response.Results) != 0 || response.NextPageToken != "" {
+		t.Errorf("expected no results and no token, got %+v", response)
+	}
+}
+
+// A token that does not belong to the query must not restart it from start_time.
+func TestRunRejectsATokenOfAnotherQuery(t *testing.T) {
+	cluster := windowCluster()
+	token := query(t, cluster, queryConfig(2)).NextPageToken
+
+	cfg := queryConfig(2)
+	cfg.ScopeID = "3"
+	cfg.NextPageToken = token
+
+	var stdout, stderr bytes.Buffer
+	connected := false
+	connect := func() (kubeclient.Interface, error) { connected = true; return cluster, nil }
+
+	if code := run(cfg, connect, &stdout, &stderr); code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "invalid next-page-token") || stdout.Len() != 0 {
+		t.Errorf("expected only an error, got stdout %q and stderr %q", stdout.String(), stderr.String())
+	}
+	if connected {
+		t.Error("expected the token to be rejected before connecting to the cluster")
+	}
+}
+
+// One unresponsive kubelet must not hold up the pods on the others.
+func TestPodsThatTimeOutAreListedAndReadAgainOnTheNextPage(t *testing.T) {
+	cluster := windowCluster()
+	cluster.Hang("ns", "app-b")
+	cfg := queryConfig(10)
+	cfg.PodTimeout = 50 * time.Millisecond
+	cfg.Timeout = time.Minute
+
+	response := query(t, cluster, cfg)
+
+	if len(response.TimedOut) != 1 || response.TimedOut[0].Name != "app-b" {
+		t.Errorf("expected app-b to time out, got %+v", response.TimedOut)
+	}
+	var messages []string
+	for _, entry := range response.Results {
+		messages = append(messages, entry.Message)
+	}
+	if !slices.Equal(messages, []string{"a first", "a second", "a third"}) {
+		t.Errorf("expected the lines of app-a, got %q", messages)
+	}
+
+	// app-b kept no cursor, so the next page reads it from start_time.
+	cfg.NextPageToken = response.NextPageToken
+	if delivered := pageThrough(t, windowCluster(), cfg); !slices.Equal(delivered, []string{"b first", "b second"}) {
+		t.Errorf("expected the lines of app-b on the next pages, got %q", delivered)
+	}
+}
+
+// A page left empty by timeouts has not reached the end of the window.
+func TestPageEmptiedByTimeoutsKeepsPaging(t *testing.T) {
+	cluster := windowCluster()
+	first := query(t, cluster, queryConfig(1))
+
+	for _, pod := range []string{"app-a", "app-b", "app-c"} {
+		cluster.Hang("ns", pod)
+	}
+	cfg := queryConfig(1)
+	cfg.NextPageToken = first.NextPageToken
+	cfg.Parallelism = 1
+	cfg.PodTimeout = time.Minute
+	cfg.Timeout = 50 * time.Millisecond
+
+	response := query(t, cluster, cfg)
+
+	if len(response.Results) != 0 || len(response.TimedOut) != 3 {
+		t.Errorf("expected every pod to time out, got %+v", response)
+	}
+	if response.NextPageToken != first.NextPageToken {
+		t.Error("expected the token to retry the page")
+	}
+}
+
+// A pod whose log cannot be read says why, rather than looking like a pod that logged nothing.
+func TestStreamsThatFailAreReportedWithTheirReason(t *testing.T) {
+	cluster := windowCluster()
+	cluster.FailLogs("ns", "app-b", apierrors.NewForbidden(corev1.Resource("pods/log"), "app-b", errors.New("RBAC denied")))
+
+	response := query(t, cluster, queryConfig(10))
+
+	if len(response.Warnings) != 1 {
+		t.Fatalf("expected a warning for app-b, got %+v", response.Warnings)
+	}
+	warning := response.Warnings[0]
+	if warning.Pod.Name != "app-b" || warning.Container != "application" || warning.Reason != "Forbidden" || !strings.Contains(warning.Message, "RBAC denied") {
+		t.Errorf("expected app-b to be forbidden, got %+v", warning)
+	}
+	if len(response.Results) != 3 {
+		t.Errorf("expected the lines of app-a, got %+v", response.Results)
+	}
+}
+
+// Scripts can tell a query that read nothing from one that found nothing.
+func TestStrictFailsWhenNoLogCouldBeRead(t *testing.T) {
+	cluster := fake.NewCluster(fake.Pod("ns", "app-a", labels, "application"))
+	cluster.FailLogs("ns", "app-a", apierrors.NewBadRequest("container \"application\" in pod \"app-a\" is waiting to start: ContainerCreating"))
+
+	for _, strict := range []bool{false, true} {
+		cfg := queryConfig(10)
+		cfg.Strict = strict
+
+		var stdout, stderr bytes.Buffer
+		code := run(cfg, func() (kubeclient.Interface, error) { return cluster, nil }, &stdout, &stderr)
+
+		want := 0
+		if strict {
+			want = 1
+		}
+		if code != want {
+			t.Errorf("strict %v: expected exit code %d, got %d", strict, want, code)
+		}
+		if !strings.Contains(stdout.String(), `"reason":"BadRequest"`) {
+			t.Errorf("strict %v: expected the response to explain the failure, got %s", strict, stdout.String())
+		}
+	}
+}
+
+// A followed pod whose log cannot be read says why, rather than staying silent.
+func TestFollowReportsStreamsThatFail(t *testing.T) {
+	cluster := windowCluster()
+	cluster.FailLogs("ns", "app-b", apierrors.NewForbidden(corev1.Resource("pods/log"), "app-b", errors.New("RBAC denied")))
+	cfg := queryConfig(10)
+	cfg.Follow = true
+	cfg.Output = output.Text
+	cfg.IdleTimeout = 500 * time.Millisecond
+
+	var stdout, stderr bytes.Buffer
+	if code := run(cfg, func() (kubeclient.Interface, error) { return cluster, nil }, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "Warning: app-b/application restart 0 could not be read: Forbidden") {
+		t.Errorf("expected app-b to be reported as forbidden, got %q", stderr.String())
+	}
+}
+
+func TestFollowRejectsWhatItCannotStream(t *testing.T) {
+	events := queryConfig(10)
+	events.IncludeEvents = true
+	previous := queryConfig(10)
+	previous.Previous = types.PreviousAuto
+
+	for name, cfg := range map[string]types.Config{"include-events": events, "previous": previous} {
+		cfg.Follow = true
+		cfg.IdleTimeout = time.Minute
+		var stdout, stderr bytes.Buffer
+		connect := func() (kubeclient.Interface, error) { t.Fatalf("%s: connected", name); return nil, nil }
+
+		if code := run(cfg, connect, &stdout, &stderr); code != 1 || !strings.HasPrefix(stderr.String(), "Error: follow") {
+			t.Errorf("%s: expected exit code 1 and an error, got %d and %q", name, code, stderr.String())
+		}
+	}
+}
+
+// A histogram counts the whole window whatever the limit, listing empty buckets too.
+func TestHistogramCountsTheWindowPerBucket(t *testing.T) {
+	cfg := queryConfig(1)
+	cfg.Histogram = 2 * time.Second
+	cfg.HistogramBy = types.HistogramByPod
+
+	var stdout, stderr bytes.Buffer
+	if code := run(cfg, func() (kubeclient.Interface, error) { return windowCluster(), nil }, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	var response types.HistogramResponse
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		t.Fatalf("output is not a histogram: %v\n%s", err, stdout.String())
+	}
+
+	if len(response.Buckets) != 30 {
+		t.Fatalf("expected a bucket every 2s of the minute, got %d", len(response.Buckets))
+	}
+	want := []types.Bucket{
+		{Start: "2026-08-17T10:00:00Z", Count: 1, Groups: map[string]int{"app-a": 1}},
+		{Start: "2026-08-17T10:00:02Z", Count: 2, Groups: map[string]int{"app-a": 1, "app-b": 1}},
+		{Start: "2026-08-17T10:00:04Z", Count: 2, Groups: map[string]int{"app-a": 1, "app-b": 1}},
+		{Start: "2026-08-17T10:00:06Z"},
+	}
+	for i, bucket := range want {
+		got := response.Buckets[i]
+		if got.Start != bucket.Start || got.Count != bucket.Count || !maps.Equal(got.Groups, bucket.Groups) {
+			t.Errorf("bucket %d: expected %+v, got %+v", i, bucket, got)
+		}
+	}
+}
+
+// Mistakes in the client options are reported like the other arguments, without a cluster.
+func TestRunRejectsClientOptionsItCannotUse(t *testing.T) {
+	groupAlone := queryConfig(10)
+	groupAlone.AsGroups = []string{"viewers"}
+	missingFile := queryConfig(10)
+	missingFile.Kubeconfig = filepath.Join(t.TempDir(), "missing")
+	negative := queryConfig(10)
+	negative.QPS = -1
+
+	for name, cfg := range map[string]types.Config{"as-group alone": groupAlone, "missing kubeconfig": missingFile, "negative qps": negative} {
+		var stdout, stderr bytes.Buffer
+		connect := func() (kubeclient.Interface, error) { t.Fatalf("%s: connected", name); return nil, nil }
+
+		if code := run(cfg, connect, &stdout, &stderr); code != 1 || !strings.HasPrefix(stderr.String(), "Error: ") {
+			t.Errorf("%s: expected exit code 1 and an error, got %d and %q", name, code, stderr.String())
+		}
+	}
+}
+
+// Operators pipe text into grep, so stdout holds nothing but lines.
+func TestTextOutputWritesOneLinePerEntry(t *testing.T) {
+	cfg := queryConfig(2)
+	cfg.Output = output.Text
+
+	var stdout, stderr bytes.Buffer
+	if code := run(cfg, func() (kubeclient.Interface, error) { return windowCluster(), nil }, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+
+	want := "2026-08-17T10:00:01.000000000Z app-a a first\n2026-08-17T10:00:02.000000000Z app-b b first\n"
+	if stdout.String() != want {
+		t.Errorf("expected %q, got %q", want, stdout.String())
+	}
+	if !strings.HasPrefix(stderr.String(), "Next page token: ") {
+		t.Errorf("expected the token on stderr, got %q", stderr.String())
+	}
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	kubeclient "k8s.io/client-go/kubernetes"
+
+	"kube-logger-go/internal/config"
+	"kube-logger-go/internal/kubernetes"
+	"kube-logger-go/internal/logs"
+	"kube-logger-go/internal/output"
+	"kube-logger-go/internal/pagination"
+	"kube-logger-go/internal/types"
+)
+
+// shutdownTimeout is how long queries in flight get to finish once the server is stopped
+const shutdownTimeout = 30 * time.Second
+
+// queryParams are the parameters of a query to the server, each setting the field of the
+// flag of the same name. Any other parameter is rejected, so a misspelled one does not widen
+// the query.
+var queryParams = []string{
+	"namespace", "all_namespaces", "application_id", "scope_id", "deployment_id", "instance_id",
+	"job_name", "execution_id", "container", "previous", "filter", "ignore_case",
+	"start_time", "end_time", "direction", "limit", "next_page_token",
+	"include_metadata", "include_events", "multiline", "multiline_start", "redact",
+}
+
+// serve answers queries over HTTP until it is interrupted, reading the cluster through one
+// client for all of them. It returns the process exit code.
+func serve(cfg types.Config, connect func() (kubeclient.Interface, error), stderr io.Writer) int {
+	// The defaults are checked once, so a mistake in them fails the server rather than every query
+	probe, _ := requestConfig(cfg, url.Values{"all_namespaces": {"true"}})
+	if _, err := validate(probe); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	clientset, err := connect()
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to create Kubernetes client: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	handler, err := newServer(ctx, cfg, clientset)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to %v\n", err)
+		return 1
+	}
+
+	httpServer := &http.Server{Addr: cfg.Listen, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	shutdown := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		shutdown <- httpServer.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(stderr, "Serving queries on %s\n", cfg.Listen)
+	if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(stderr, "Failed to serve: %v\n", err)
+		return 1
+	}
+
+	// ListenAndServe returns as soon as the shutdown starts; the queries in flight are only
+	// answered once it ends
+	if err := <-shutdown; err != nil {
+		fmt.Fprintf(stderr, "Failed to finish the queries in flight: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// server answers queries for a page of logs at /logs, with the same response as the command.
+// The sources, and so the client, the pod index, the archive and the cache, are kept for
+// every query.
+type server struct {
+	defaults types.Config
+	sources  []logs.LogSource
+}
+
+// newServer creates the server of queries whose defaults are cfg. The pods of the namespaces
+// of cfg, the only ones it answers for, are indexed until ctx is done.
+func newServer(ctx context.Context, cfg types.Config, clientset kubeclient.Interface) (*server, error) {
+	index := kubernetes.NewPodIndex(clientset, cfg)
+	if err := index.Start(ctx); err != nil {
+		return nil, fmt.Errorf("index pods: %v", err)
+	}
+
+	sources, _, err := openSources(cfg, logs.NewIndexedSource(clientset, index))
+	if err != nil {
+		return nil, err
+	}
+	return &server{defaults: cfg, sources: sources}, nil
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/healthz":
+		w.WriteHeader(http.StatusOK)
+		return
+	case r.URL.Path != "/logs":
+		writeJSON(w, http.StatusNotFound, types.ErrorResponse{Error: "not found; queries go to /logs"})
+		return
+	case r.Method != http.MethodGet:
+		writeJSON(w, http.StatusMethodNotAllowed, types.ErrorResponse{Error: "queries are GET requests"})
+		return
+	}
+
+	cfg, err := requestConfig(s.defaults, r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+	processor, err := validate(cfg)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := s.allows(cfg); err != nil {
+		writeJSON(w, http.StatusForbidden, types.ErrorResponse{Error: err.Error()})
+		return
+	}
+	codec := pagination.NewCodec(cfg.TokenKey, cfg)
+	cursors, err := codec.Decode(cfg.NextPageToken)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, types.ErrorResponse{Error: fmt.Sprintf("invalid next_page_token: %v", err)})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), cfg.Timeout)
+	defer cancel()
+
+	response, _, err := readPage(ctx, logs.NewFetcher(processor, s.sources...), cfg, codec, cursors)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, types.ErrorResponse{Error: fmt.Sprintf("failed to get pods: %v", err)})
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// allows checks that a query only reads the namespaces the server was started with. Queries
+// run with the server's credentials, so a server of some namespaces must not read others;
+// only one started with --all-namespaces answers for every namespace.
+func (s *server) allows(cfg types.Config) error {
+	if s.defaults.AllNamespaces {
+		return nil
+	}
+	if cfg.AllNamespaces {
+		return fmt.Errorf("this server only answers for namespaces %s", strings.Join(s.defaults.Namespaces, ","))
+	}
+	for _, namespace := range cfg.Namespaces {
+		if !slices.Contains(s.defaults.Namespaces, namespace) {
+			return fmt.Errorf("namespace %q is not served; this server only answers for namespaces %s", namespace, strings.Join(s.defaults.Namespaces, ","))
+		}
+	}
+	return nil
+}
+
+// requestConfig sets the fields of the defaults that the parameters of a query name
+func requestConfig(defaults types.Config, params url.Values) (types.Config, error) {
+	for name := range params {
+		if !slices.Contains(queryParams, name) {
+			return types.Config{}, fmt.Errorf("unknown parameter %q", name)
+		}
+	}
+
+	cfg := defaults
+	stringParams := map[string]*string{
+		"application_id":  &cfg.ApplicationID,
+		"scope_id":        &cfg.ScopeID,
+		"deployment_id":   &cfg.DeploymentID,
+		"instance_id":     &cfg.InstanceID,
+		"job_name":        &cfg.JobName,
+		"execution_id":    &cfg.ExecutionID,
+		"filter":          &cfg.FilterPattern,
+		"start_time":      &cfg.StartTime,
+		"end_time":        &cfg.EndTime,
+		"direction":       &cfg.Direction,
+		"next_page_token": &cfg.NextPageToken,
+		"multiline_start": &cfg.MultilineStart,
+	}
+	for name, field := range stringParams {
+		if params.Has(name) {
+			*field = params.Get(name)
+		}
+	}
+
+	boolParams := map[string]*bool{
+		"all_namespaces":   &cfg.AllNamespaces,
+		"ignore_case":      &cfg.IgnoreCase,
+		"include_metadata": &cfg.IncludeMetadata,
+		"include_events":   &cfg.IncludeEvents,
+		"multiline":        &cfg.Multiline,
+		"redact":           &cfg.Redact,
+	}
+	for name, field := range boolParams {
+		if !params.Has(name) {
+			continue
+		}
+		value, err := strconv.ParseBool(params.Get(name))
+		if err != nil {
+			return types.Config{}, fmt.Errorf("%s must be true or false (got %q)", name, params.Get(name))
+		}
+		*field = value
+	}
+
+	// A server started with --redact redacts every query, whatever it asks for
+	cfg.Redact = cfg.Redact || defaults.Redact
+
+	// The namespaces of a query replace those of the defaults rather than add to them
+	if params.Has("namespace") || params.Has("all_namespaces") {
+		cfg.Namespaces = nil
+		for _, value := range params["namespace"] {
+			cfg.Namespaces = append(cfg.Namespaces, splitList(value)...)
+		}
+		cfg.AllNamespaces = cfg.AllNamespaces && params.Has("all_namespaces")
+	}
+	if params.Has("container") {
+		cfg.Containers = nil
+		for _, value := range params["container"] {
+			cfg.Containers = append(cfg.Containers, splitList(value)...)
+		}
+	}
+	if params.Has("previous") {
+		previous, err := config.ParsePrevious(params.Get("previous"))
+		if err != nil {
+			return types.Config{}, fmt.Errorf("previous %v (got %q)", err, params.Get("previous"))
+		}
+		cfg.Previous = previous
+	}
+	if params.Has("limit") {
+		// Pages are held in memory, by the target too when paging backward, so a caller cannot
+		// ask for more than the server is willing to hold
+		limit, err := strconv.Atoi(params.Get("limit"))
+		if err != nil || limit <= 0 || limit > types.MaxServedLimit {
+			return types.Config{}, fmt.Errorf("limit must be between 1 and %d (got %q)", types.MaxServedLimit, params.Get("limit"))
+		}
+		cfg.Limit = limit
+	}
+
+	// The server answers pages of JSON, whatever its own flags say
+	cfg.Output = output.JSON
+	cfg.Follow = false
+	cfg.Histogram = 0
+	cfg.HistogramBy = ""
+	return cfg, nil
+}
+
+// queryValues are the parameters that send a query to a server
+func queryValues(cfg types.Config) url.Values {
+	params := url.Values{}
+	if cfg.AllNamespaces {
+		params.Set("all_namespaces", "true")
+	} else {
+		params.Set("namespace", strings.Join(cfg.Namespaces, ","))
+	}
+	for name, value := range map[string]string{
+		"application_id":  cfg.ApplicationID,
+		"scope_id":        cfg.ScopeID,
+		"deployment_id":   cfg.DeploymentID,
+		"instance_id":     cfg.InstanceID,
+		"job_name":        cfg.JobName,
+		"execution_id":    cfg.ExecutionID,
+		"filter":          cfg.FilterPattern,
+		"start_time":      cfg.StartTime,
+		"end_time":        cfg.EndTime,
+		"direction":       cfg.Direction,
+		"next_page_token": cfg.NextPageToken,
+		"multiline_start": cfg.MultilineStart,
+	} {
+		if value != "" {
+			params.Set(name, value)
+		}
+	}
+	for name, value := range map[string]bool{
+		"ignore_case":      cfg.IgnoreCase,
+		"include_metadata": cfg.IncludeMetadata,
+		"include_events":   cfg.IncludeEvents,
+		"multiline":        cfg.Multiline,
+		"redact":           cfg.Redact,
+	} {
+		if value {
+			params.Set(name, "true")
+		}
+	}
+	// Never is the empty mode, which would otherwise leave the server's own
+	previous := cfg.Previous
+	if previous == types.PreviousNever {
+		previous = "never"
+	}
+	params.Set("previous", previous)
+	params["container"] = cfg.Containers
+	params.Set("limit", strconv.Itoa(cfg.Limit))
+	return params
+}
+
+// remote sends a query to the server and writes its page, as run writes one read from the
+// cluster. It returns the process exit code.
+func remote(cfg types.Config, stdout, stderr io.Writer) int {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout+shutdownTimeout)
+	defer cancel()
+
+	endpoint := strings.TrimSuffix(cfg.Server, "/") + "/logs?" + queryValues(cfg).Encode()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: invalid server %q: %v\n", cfg.Server, err)
+		return 1
+	}
+	httpResponse, err := http.DefaultClient.Do(request)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to reach the server: %v\n", err)
+		return 1
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		var failure types.ErrorResponse
+		if err := json.NewDecoder(httpResponse.Body).Decode(&failure); err != nil || failure.Error == "" {
+			failure.Error = httpResponse.Status
+		}
+		if httpResponse.StatusCode == http.StatusBadRequest {
+			fmt.Fprintf(stderr, "Error: %s\n", failure.Error)
+		} else {
+			fmt.Fprintf(stderr, "Failed to query the server: %s\n", failure.Error)
+		}
+		return 1
+	}
+
+	var response types.Response
+	if err := json.NewDecoder(httpResponse.Body).Decode(&response); err != nil {
+		fmt.Fprintf(stderr, "Failed to read the server's response: %v\n", err)
+		return 1
+	}
+	if err := output.WritePage(stdout, stderr, response, cfg.Output, output.UseColor(cfg.Color, stdout)); err != nil {
+		fmt.Fprintf(stderr, "Failed to write the response: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// writeJSON answers a request with a JSON body
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// splitList splits a comma-separated parameter, as the flags of the command accept them
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	kubeclient "k8s.io/client-go/kubernetes"
+
+	"kube-logger-go/internal/kubernetes/fake"
+	"kube-logger-go/internal/types"
+)
+
+// startServer serves queries against a cluster, with the flags of serve as its defaults
+func startServer(t *testing.T, cluster *fake.Cluster) *httptest.Server {
+	t.Helper()
+
+	defaults := queryConfig(types.DefaultLimit)
+	defaults.ApplicationID, defaults.ScopeID, defaults.StartTime, defaults.EndTime = "", "", "", ""
+
+	handler, err := newServer(t.Context(), defaults, cluster)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPagesFromTheServerMatchTheCommand(t *testing.T) {
+	direct := pageThrough(t, windowCluster(), queryConfig(2))
+
+	cfg := queryConfig(2)
+	cfg.Server = startServer(t, windowCluster()).URL
+
+	if served := pageThrough(t, nil, cfg); !slices.Equal(served, direct) {
+		t.Errorf("expected the pages of the command %q, got %q", direct, served)
+	}
+}
+
+func TestServerRejectsQueriesItCannotServe(t *testing.T) {
+	server := startServer(t, windowCluster())
+
+	for name, params := range map[string]string{
+		"unknown parameter": "namespace=ns&aplication_id=1",
+		"both namespaces":   "namespace=ns&all_namespaces=true",
+		"bad limit":         "namespace=ns&limit=-1",
+		"huge limit":        "namespace=ns&limit=2147483647",
+		"bad previous":      "namespace=ns&previous=sometimes",
+		"bad token":         "namespace=ns&next_page_token=garbage",
+	} {
+		response, err := http.Get(server.URL + "/logs?" + params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var failure types.ErrorResponse
+		json.NewDecoder(response.Body).Decode(&failure)
+		response.Body.Close()
+
+		if response.StatusCode != http.StatusBadRequest || failure.Error == "" {
+			t.Errorf("%s: expected 400 with an error, got %d and %+v", name, response.StatusCode, failure)
+		}
+	}
+}
+
+// Queries run with the server's credentials, so they cannot reach past its namespaces.
+func TestServerOnlyAnswersForItsNamespaces(t *testing.T) {
+	server := startServer(t, windowCluster())
+
+	for params, status := range map[string]int{
+		"namespace=ns":             http.StatusOK,
+		"namespace=ns,kube-system": http.StatusForbidden,
+		"namespace=kube-system":    http.StatusForbidden,
+		"all_namespaces=true":      http.StatusForbidden,
+	} {
+		response, err := http.Get(server.URL + "/logs?application_id=1&scope_id=2&" + params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+
+		if response.StatusCode != status {
+			t.Errorf("%s: expected %d, got %d", params, status, response.StatusCode)
+		}
+	}
+}
+
+// The command reports the server's refusal as it reports its own, without a cluster.
+func TestRemoteQueryReportsTheServersError(t *testing.T) {
+	cfg := queryConfig(10)
+	cfg.Server = startServer(t, windowCluster()).URL
+	cfg.NextPageToken = "garbage"
+
+	var stdout, stderr bytes.Buffer
+	connect := func() (kubeclient.Interface, error) { t.Fatal("connected"); return nil, nil }
+
+	if code := run(cfg, connect, &stdout, &stderr); code != 1 || !strings.HasPrefix(stderr.String(), "Error: invalid next_page_token") {
+		t.Errorf("expected exit code 1 and the server's error, got %d and %q", code, stderr.String())
+	}
+}
+
+// Redaction asked for by the client is applied by the server, rather than silently dropped.
+func TestRemoteQueryIsRedacted(t *testing.T) {
+	cluster := fake.NewCluster(fake.Pod("ns", "app-a", labels, "application"))
+	cluster.AddLogs("ns", "app-a", "application", false, "2026-08-17T10:00:01.000000000Z login by jane@example.com")
+	cfg := queryConfig(10)
+	cfg.Server = startServer(t, cluster).URL
+	cfg.Redact = true
+
+	response := query(t, nil, cfg)
+	if response.Redactions != 1 || response.Results[0].Message != "login by [REDACTED:email]" {
+		t.Errorf("expected the email redacted by the server, got %d in %+v", response.Redactions, response.Results)
+	}
+}
+
+func TestRemoteQueryRejectsOptionsOfTheServer(t *testing.T) {
+	rules := queryConfig(10)
+	rules.RedactRules = "rules.json"
+	parallelism := queryConfig(10)
+	parallelism.Parallelism = 1
+	timeout := queryConfig(10)
+	timeout.Timeout = time.Minute
+
+	for name, cfg := range map[string]types.Config{"redact-rules": rules, "parallelism": parallelism, "timeout": timeout} {
+		cfg.Server = "http://kube-logger.invalid"
+		var stdout, stderr bytes.Buffer
+		connect := func() (kubeclient.Interface, error) { t.Fatalf("%s: connected", name); return nil, nil }
+
+		if code := run(cfg, connect, &stdout, &stderr); code != 1 || !strings.HasPrefix(stderr.String(), "Error: ") {
+			t.Errorf("%s: expected exit code 1 and an error, got %d and %q", name, code, stderr.String())
+		}
+	}
+}
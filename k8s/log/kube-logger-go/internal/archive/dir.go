@@ -0,0 +1,67 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// DirStore is an archive in a local directory, e.g. a volume log shippers write to
+type DirStore struct {
+	root string
+}
+
+// NewDirStore opens the archive in directory root
+func NewDirStore(root string) (*DirStore, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %v", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("archive %s is not a directory", root)
+	}
+	return &DirStore{root: root}, nil
+}
+
+// List returns the keys of the files under prefix. A prefix that does not exist holds nothing.
+func (s *DirStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(filepath.Join(s.root, filepath.FromSlash(prefix)), func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if entry.Type().IsRegular() {
+			relative, err := filepath.Rel(s.root, name)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, filepath.ToSlash(relative))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive: %v", err)
+	}
+	return keys, nil
+}
+
+// Open reads the file at key
+func (s *DirStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	// Keys come from List, but a cleaned key cannot step out of the archive in any case.
+	file, err := os.Open(filepath.Join(s.root, filepath.FromSlash(path.Clean("/"+key))))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archived log: %v", err)
+	}
+	return file, nil
+}
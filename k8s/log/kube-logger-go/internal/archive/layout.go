@@ -0,0 +1,105 @@
+package archive
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The archive keeps a directory per pod, under its namespace and UID:
+//
+//	<namespace>/<pod uid>/pod.json                    {"name": "...", "labels": {...}}
+//	<namespace>/<pod uid>/<container>/<restart>.log   the log as the logs API returns it with timestamps
+//
+// restart is the restart count of the container while the instance ran, 0 for the first.
+// pod.json carries the labels queries select pods by, so pods without one are left out.
+const podFile = "pod.json"
+
+// Pod is a pod whose logs are archived
+type Pod struct {
+	Namespace string
+	UID       string
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels"`
+	Logs      []Log
+}
+
+// Log is the archived log of one container instance
+type Log struct {
+	Container string
+	Restart   int
+}
+
+// LogKey returns the key of the archived log of a container instance
+func LogKey(namespace, podUID, container string, restart int) string {
+	return path.Join(namespace, podUID, container, strconv.Itoa(restart)+".log")
+}
+
+// ListPods returns the archived pods of a namespace, or of every namespace when it is empty,
+// each with its logs ordered by container and restart. Every namespace takes listing the
+// whole archive.
+func ListPods(ctx context.Context, store Store, namespace string) ([]Pod, error) {
+	prefix := ""
+	if namespace != "" {
+		prefix = namespace + "/"
+	}
+	keys, err := store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pods are keyed by their directory, <namespace>/<pod uid>.
+	logs := make(map[string][]Log)
+	var dirs []string
+	for _, key := range keys {
+		parts := strings.Split(key, "/")
+		switch {
+		case len(parts) == 3 && parts[2] == podFile:
+			dirs = append(dirs, path.Join(parts[0], parts[1]))
+		case len(parts) == 4 && strings.HasSuffix(parts[3], ".log"):
+			restart, err := strconv.Atoi(strings.TrimSuffix(parts[3], ".log"))
+			if err != nil || restart < 0 {
+				continue
+			}
+			dir := path.Join(parts[0], parts[1])
+			logs[dir] = append(logs[dir], Log{Container: parts[2], Restart: restart})
+		}
+	}
+	slices.Sort(dirs)
+
+	pods := make([]Pod, 0, len(dirs))
+	for _, dir := range dirs {
+		pod, err := readPod(ctx, store, path.Join(dir, podFile))
+		if err != nil {
+			return nil, err
+		}
+		pod.Namespace, pod.UID = path.Split(dir)
+		pod.Namespace = strings.TrimSuffix(pod.Namespace, "/")
+		pod.Logs = logs[dir]
+		slices.SortFunc(pod.Logs, func(a, b Log) int {
+			return cmp.Or(strings.Compare(a.Container, b.Container), cmp.Compare(a.Restart, b.Restart))
+		})
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
+// readPod reads the metadata of an archived pod
+func readPod(ctx context.Context, store Store, key string) (Pod, error) {
+	file, err := store.Open(ctx, key)
+	if err != nil {
+		return Pod{}, err
+	}
+	defer file.Close()
+
+	var pod Pod
+	if err := json.NewDecoder(file).Decode(&pod); err != nil {
+		return Pod{}, fmt.Errorf("archived pod %s is not valid JSON: %v", key, err)
+	}
+	return pod, nil
+}
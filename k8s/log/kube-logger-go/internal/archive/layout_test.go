@@ -0,0 +1,98 @@
+package archive
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeArchive lays files out in a directory archive, by key
+func writeArchive(t *testing.T, files map[string]string) *DirStore {
+	t.Helper()
+
+	root := t.TempDir()
+	for key, content := range files {
+		name := filepath.Join(root, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, err := NewDirStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestListPodsReadsThePodsOfANamespace(t *testing.T) {
+	store := writeArchive(t, map[string]string{
+		"ns/uid-a/pod.json":              `{"name": "app-a", "labels": {"scope_id": "1"}}`,
+		"ns/uid-a/application/1.log":     "",
+		"ns/uid-a/application/0.log":     "",
+		"ns/uid-a/istio-proxy/0.log":     "",
+		"ns/uid-a/application/notes.txt": "",
+		"ns/uid-b/application/0.log":     "",
+		"other/uid-c/pod.json":           `{"name": "app-c"}`,
+	})
+
+	pods, err := ListPods(context.Background(), store, "ns")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pod b has no pod.json, so there is nothing to select it by.
+	if len(pods) != 1 {
+		t.Fatalf("expected only pod a, got %+v", pods)
+	}
+	pod := pods[0]
+	if pod.UID != "uid-a" || pod.Name != "app-a" || pod.Labels["scope_id"] != "1" {
+		t.Errorf("expected the metadata of pod a, got %+v", pod)
+	}
+
+	want := []Log{{"application", 0}, {"application", 1}, {"istio-proxy", 0}}
+	if len(pod.Logs) != len(want) {
+		t.Fatalf("expected logs %v, got %v", want, pod.Logs)
+	}
+	for i := range want {
+		if pod.Logs[i] != want[i] {
+			t.Errorf("expected logs %v, got %v", want, pod.Logs)
+		}
+	}
+}
+
+func TestListPodsOfAnEmptyNamespace(t *testing.T) {
+	pods, err := ListPods(context.Background(), writeArchive(t, nil), "ns")
+	if err != nil || len(pods) != 0 {
+		t.Errorf("expected no pods and no error, got %v, %v", pods, err)
+	}
+}
+
+func TestListPodsOfEveryNamespace(t *testing.T) {
+	store := writeArchive(t, map[string]string{
+		"ns/uid-a/pod.json":    `{"name": "app-a"}`,
+		"other/uid-a/pod.json": `{"name": "app-a"}`,
+	})
+
+	pods, err := ListPods(context.Background(), store, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pods) != 2 || pods[0].Namespace != "ns" || pods[1].Namespace != "other" || pods[1].UID != "uid-a" {
+		t.Errorf("expected pod a of both namespaces, got %+v", pods)
+	}
+}
+
+func TestOpenRejectsOtherSchemes(t *testing.T) {
+	if _, err := Open(context.Background(), "https://example.com/logs"); err == nil {
+		t.Error("expected an https archive to be rejected")
+	}
+	if _, err := Open(context.Background(), "s3://"); err == nil {
+		t.Error("expected an archive without a bucket to be rejected")
+	}
+}
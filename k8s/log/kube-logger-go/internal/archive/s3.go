@@ -0,0 +1,70 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Store is an archive in an S3 bucket, or any store speaking its API. Credentials, region
+// and endpoint come from the usual AWS environment; AWS_ENDPOINT_URL points it at e.g.
+// LocalStack or MinIO.
+type S3Store struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Store opens the archive under prefix in bucket
+func NewS3Store(ctx context.Context, bucket, prefix string) (*S3Store, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %v", err)
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		// Stores other than S3 seldom resolve bucket subdomains.
+		o.UsePathStyle = cfg.BaseEndpoint != nil
+	})
+
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &S3Store{client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+// List returns the keys of the objects under prefix
+func (s *S3Store) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.prefix + prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list archive: %v", err)
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, strings.TrimPrefix(aws.ToString(object.Key), s.prefix))
+		}
+	}
+	return keys, nil
+}
+
+// Open reads the object at key
+func (s *S3Store) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archived log: %v", err)
+	}
+	return object.Body, nil
+}
@@ -0,0 +1,36 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Store is where log files of pods that no longer run are kept. Keys are slash-separated
+// paths relative to the root of the archive.
+type Store interface {
+	// List returns the keys under prefix, in no particular order
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Open reads the object at key
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// Open opens the archive at location: an s3:// URL of a bucket and optional prefix, or a local
+// directory, as a path or a file:// URL
+func Open(ctx context.Context, location string) (Store, error) {
+	switch {
+	case strings.HasPrefix(location, "s3://"):
+		bucket, prefix, _ := strings.Cut(strings.TrimPrefix(location, "s3://"), "/")
+		if bucket == "" {
+			return nil, fmt.Errorf("archive %q names no bucket", location)
+		}
+		return NewS3Store(ctx, bucket, prefix)
+	case strings.HasPrefix(location, "file://"):
+		return NewDirStore(strings.TrimPrefix(location, "file://"))
+	case strings.Contains(location, "://"):
+		return nil, fmt.Errorf("archive %q must be a directory or an s3:// URL", location)
+	default:
+		return NewDirStore(location)
+	}
+}
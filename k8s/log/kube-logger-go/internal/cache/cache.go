@@ -0,0 +1,157 @@
+// Package cache keeps reads of pod logs in a local directory, so the same read asked for
+// again, by the next page of another user looking at the same incident, is not streamed from
+// the kubelet again.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// suffix marks the files of cached reads, so eviction leaves anything else in the directory
+// alone
+const suffix = ".log"
+
+// headerBytes is the size of the header of a cached file, which holds when it was put as Unix
+// nanoseconds. The modification time of the file is when it was last read instead.
+const headerBytes = 8
+
+// Cache is a directory of cached reads. Entries put more than the TTL ago are not served,
+// however often they are read, and the least recently read are evicted once the directory
+// holds more than its size. Several processes may share a directory.
+type Cache struct {
+	dir      string
+	ttl      time.Duration
+	maxBytes int64
+	now      func() time.Time
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// Stats counts the lookups of a cache
+type Stats struct {
+	Hits   int64
+	Misses int64
+}
+
+// Open opens the cache in dir, creating the directory if needed
+func Open(dir string, ttl time.Duration, maxBytes int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	return &Cache{dir: dir, ttl: ttl, maxBytes: maxBytes, now: time.Now}, nil
+}
+
+// Get returns the data cached under key, if it is there and has not expired
+func (c *Cache) Get(key string) ([]byte, bool) {
+	name := c.path(key)
+	data, err := os.ReadFile(name)
+	if err != nil || len(data) < headerBytes {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	now := c.now()
+	put := time.Unix(0, int64(binary.BigEndian.Uint64(data[:headerBytes])))
+	if now.Sub(put) > c.ttl {
+		os.Remove(name)
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	// Touching the file keeps what is read often from being evicted, while the header keeps
+	// when it was put for the TTL
+	os.Chtimes(name, now, now)
+	c.hits.Add(1)
+	return data[headerBytes:], true
+}
+
+// Put caches data under key, then evicts what has expired and what the size does not fit
+func (c *Cache) Put(key string, data []byte) error {
+	temp, err := os.CreateTemp(c.dir, ".put-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	header := binary.BigEndian.AppendUint64(nil, uint64(c.now().UnixNano()))
+	if _, err := temp.Write(append(header, data...)); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	// Renaming is atomic, so another process never reads a file half written
+	if err := os.Rename(temp.Name(), c.path(key)); err != nil {
+		return err
+	}
+	return c.evict()
+}
+
+// Stats returns the hits and misses of the cache so far
+func (c *Cache) Stats() Stats {
+	return Stats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// path is the file of key. Keys are hashed, as they hold characters file names cannot.
+func (c *Cache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+suffix)
+}
+
+// cachedFile is a file of the cache, as eviction sees it
+type cachedFile struct {
+	name     string
+	size     int64
+	modified time.Time
+}
+
+// evict removes the files not read for longer than the TTL, which have expired too, then the
+// least recently used until the rest fit in the size of the cache. Failing to remove a file,
+// which another process may have removed first, is no reason to fail the read that cached it.
+func (c *Cache) evict() error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	var files []cachedFile
+	var total int64
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasSuffix(entry.Name(), suffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		name := filepath.Join(c.dir, entry.Name())
+		if c.now().Sub(info.ModTime()) > c.ttl {
+			os.Remove(name)
+			continue
+		}
+		files = append(files, cachedFile{name: name, size: info.Size(), modified: info.ModTime()})
+		total += info.Size()
+	}
+
+	slices.SortFunc(files, func(a, b cachedFile) int { return a.modified.Compare(b.modified) })
+	for _, file := range files {
+		if total <= c.maxBytes {
+			break
+		}
+		os.Remove(file.name)
+		total -= file.size
+	}
+	return nil
+}
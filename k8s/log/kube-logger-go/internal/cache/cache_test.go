@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCacheServesWhatWasPut(t *testing.T) {
+	cache, err := Open(t.TempDir(), time.Hour, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := cache.Get("ns/uid-a/application"); ok {
+		t.Error("expected a miss before anything was put")
+	}
+	if err := cache.Put("ns/uid-a/application", []byte("lines")); err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := cache.Get("ns/uid-a/application"); !ok || string(data) != "lines" {
+		t.Errorf("expected a hit with the lines put, got %q, %v", data, ok)
+	}
+
+	if stats := cache.Stats(); stats != (Stats{Hits: 1, Misses: 1}) {
+		t.Errorf("expected 1 hit and 1 miss, got %+v", stats)
+	}
+}
+
+func TestCacheDoesNotServeExpiredReads(t *testing.T) {
+	cache, err := Open(t.TempDir(), time.Minute, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := time.Now()
+	cache.now = func() time.Time { return put }
+	if err := cache.Put("a", []byte("lines")); err != nil {
+		t.Fatal(err)
+	}
+
+	cache.now = func() time.Time { return put.Add(2 * time.Minute) }
+	if _, ok := cache.Get("a"); ok {
+		t.Error("expected a read older than the TTL to miss")
+	}
+}
+
+// Reading an entry keeps it from being evicted, not from expiring.
+func TestCacheExpiresReadsThatAreReadOften(t *testing.T) {
+	cache, err := Open(t.TempDir(), time.Minute, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := time.Now()
+	cache.now = func() time.Time { return put }
+	if err := cache.Put("a", []byte("lines")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, after := range []time.Duration{20 * time.Second, 40 * time.Second, 55 * time.Second} {
+		cache.now = func() time.Time { return put.Add(after) }
+		if _, ok := cache.Get("a"); !ok {
+			t.Fatalf("expected a hit %s after the put", after)
+		}
+	}
+	cache.now = func() time.Time { return put.Add(70 * time.Second) }
+	if _, ok := cache.Get("a"); ok {
+		t.Error("expected a read put longer than the TTL ago to miss, however often it was read")
+	}
+}
+
+// Reading an entry keeps it from being the next evicted.
+func TestCacheEvictsTheLeastRecentlyUsedBeyondItsSize(t *testing.T) {
+	// Two entries of 5 bytes fit, with their headers
+	cache, err := Open(t.TempDir(), time.Hour, 2*(5+headerBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, key := range []string{"a", "b"} {
+		if err := cache.Put(key, []byte("12345")); err != nil {
+			t.Fatal(err)
+		}
+		earlier := time.Now().Add(time.Duration(i-10) * time.Second)
+		os.Chtimes(cache.path(key), earlier, earlier)
+	}
+	cache.Get("a")
+
+	if err := cache.Put("c", []byte("12345")); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, err := os.Stat(cache.path(key)); (err == nil) != want {
+			t.Errorf("%s: expected kept %v, got error %v", key, want, err)
+		}
+	}
+}
@@ -2,36 +2,168 @@ package config
 
 import (
 	"flag"
+	"fmt"
+	"os"
+	"strings"
 
+	"kube-logger-go/internal/output"
 	"kube-logger-go/internal/types"
 )
 
-// ParseFlags parses command line flags and returns a Config
+// stringList collects the values of a flag that may be given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// commaList collects the values of a flag that may be given more than once or as a
+// comma-separated list
+type commaList []string
+
+func (l *commaList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *commaList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// previousMode accepts --previous alone as "always", or --previous=auto
+type previousMode string
+
+func (m *previousMode) String() string {
+	return string(*m)
+}
+
+func (m *previousMode) Set(value string) error {
+	mode, err := ParsePrevious(value)
+	if err != nil {
+		return err
+	}
+	*m = previousMode(mode)
+	return nil
+}
+
+func (m *previousMode) IsBoolFlag() bool {
+	return true
+}
+
+// ParsePrevious reads a previous-instance mode as --previous accepts it
+func ParsePrevious(value string) (string, error) {
+	switch value {
+	case "true", types.PreviousAlways:
+		return types.PreviousAlways, nil
+	case "false", "never":
+		return types.PreviousNever, nil
+	case types.PreviousAuto:
+		return types.PreviousAuto, nil
+	default:
+		return "", fmt.Errorf("must be auto, always or never")
+	}
+}
+
+// TokenKeyEnv names the environment variable holding the key that signs pagination tokens
+const TokenKeyEnv = "KUBE_LOGGER_TOKEN_KEY"
+
+// ParseFlags parses command line flags and returns a Config. A first argument of serve
+// answers queries over HTTP instead, with the flags as the defaults of every query.
 func ParseFlags() types.Config {
-	config := types.Config{Limit: types.DefaultLimit}
+	config := types.Config{
+		Limit:       types.DefaultLimit,
+		Direction:   types.DirectionForward,
+		IdleTimeout: types.DefaultIdleTimeout,
+		Parallelism: types.DefaultParallelism,
+		PodTimeout:  types.DefaultPodTimeout,
+		Timeout:     types.DefaultTimeout,
+		Output:      output.JSON,
+		Color:       output.ColorAuto,
+	}
+
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "serve" {
+		config.Serve = true
+		args = args[1:]
+	}
 
 	// Long flags
-	flag.StringVar(&config.Namespace, "namespace", "", "Kubernetes namespace")
+	flag.Var((*commaList)(&config.Namespaces), "namespace", "Kubernetes namespace, repeatable or comma-separated")
+	flag.BoolVar(&config.AllNamespaces, "all-namespaces", false, "Read the matching pods of every namespace")
 	flag.StringVar(&config.ApplicationID, "application-id", "", "Application ID")
 	flag.StringVar(&config.ScopeID, "scope-id", "", "Scope ID")
 	flag.StringVar(&config.DeploymentID, "deployment-id", "", "Deployment ID")
 	flag.IntVar(&config.Limit, "limit", types.DefaultLimit, "Maximum log entries")
 	flag.StringVar(&config.NextPageToken, "next-page-token", "", "Pagination token")
-	flag.StringVar(&config.FilterPattern, "filter", "", "Filter pattern")
+	flag.StringVar(&config.FilterPattern, "filter", "", "Filter query: words, \"phrases\", -exclusions, OR, /regex/ or /regex/i")
+	flag.BoolVar(&config.IgnoreCase, "ignore-case", false, "Match the filter regardless of case")
 	flag.StringVar(&config.StartTime, "start-time", "", "Start time (ISO format)")
 	flag.StringVar(&config.EndTime, "end-time", "", "End time (ISO format)")
 	flag.StringVar(&config.InstanceID, "instance-id", "", "Instance ID")
+	flag.StringVar(&config.JobName, "job-name", "", "Read only the pods of the scheduled task execution run by this Job")
+	flag.StringVar(&config.ExecutionID, "execution-id", "", "Read only the pods of the scheduled task execution with this Job UID")
+	flag.StringVar(&config.Direction, "direction", types.DirectionForward, "Paging direction: forward from start-time, or backward from end-time (newest first)")
+	flag.Var((*previousMode)(&config.Previous), "previous", "Also read the instance before each container's last restart; \"auto\" only when it ended inside the window")
+	flag.BoolVar(&config.Multiline, "multiline", false, "Join stack trace lines into the entry that logged them")
+	flag.StringVar(&config.MultilineStart, "multiline-start", "", "Regex matching the first line of an entry; other lines join the one before (implies --multiline)")
+	flag.BoolVar(&config.Follow, "follow", false, "Stream new lines as newline-delimited JSON until interrupted")
+	flag.DurationVar(&config.IdleTimeout, "idle-timeout", types.DefaultIdleTimeout, "Stop following after this long without new lines")
+	flag.IntVar(&config.Parallelism, "parallelism", types.DefaultParallelism, "Maximum container logs read at once")
+	flag.DurationVar(&config.PodTimeout, "pod-timeout", types.DefaultPodTimeout, "Give up on a container log after this long and list its pod as timed out")
+	flag.DurationVar(&config.Timeout, "timeout", types.DefaultTimeout, "Return what was read after this long, listing the pods not read in time")
+	flag.DurationVar(&config.Histogram, "histogram", 0, "Count the entries of the window per bucket of this length, e.g. 1m, instead of returning them")
+	flag.StringVar(&config.HistogramBy, "histogram-by", "", "Split histogram counts by pod or level")
+	flag.BoolVar(&config.Redact, "redact", false, "Redact bearer tokens, AWS keys, JWTs, emails and card numbers from messages")
+	flag.StringVar(&config.RedactRules, "redact-rules", "", "JSON file of extra redaction rules, [{\"name\": ..., \"pattern\": ...}] (implies --redact)")
+	flag.StringVar(&config.Output, "output", output.JSON, "Output format: json, ndjson, text (timestamp pod message) or csv")
+	flag.StringVar(&config.Color, "color", output.ColorAuto, "Color text output: auto (on a terminal), always or never")
+	flag.BoolVar(&config.IncludeMetadata, "include-metadata", false, "Attach deployment, scope, node, image tag and restart count to every entry")
+	flag.BoolVar(&config.IncludeEvents, "include-events", false, "Interleave the Kubernetes events of the pods, such as probe failures and image pull errors, with their logs")
+	flag.BoolVar(&config.Strict, "strict", false, "Exit with status 1 when no container log could be read")
+	flag.StringVar(&config.Archive, "archive", "", "Also read pods that no longer run from this directory or s3://bucket/prefix (not when following)")
+	flag.StringVar(&config.CacheDir, "cache-dir", "", "Cache reads of pod logs that can no longer change in this directory, shared by every request using it")
+	flag.DurationVar(&config.CacheTTL, "cache-ttl", types.DefaultCacheTTL, "How long a cached read is served")
+	flag.Int64Var(&config.CacheMaxBytes, "cache-max-bytes", types.DefaultCacheMaxBytes, "Size of the cache directory; the least recently used reads are evicted beyond it")
+	flag.StringVar(&config.Listen, "listen", types.DefaultListen, "Address serve answers queries on; it does not authenticate them, so expose it with care")
+	flag.StringVar(&config.Server, "server", "", "Send the query to the kube-logger serve at this URL; cluster, archive, cache, redaction rules, parallelism and timeouts are the server's")
+	flag.BoolVar(&config.Verbose, "verbose", false, "Report cache hits and misses to stderr")
+	flag.StringVar(&config.Kubeconfig, "kubeconfig", "", "Path to the kubeconfig file (default in-cluster, then ~/.kube/config)")
+	flag.StringVar(&config.Context, "context", "", "Kubeconfig context to use (default its current context)")
+	flag.StringVar(&config.As, "as", "", "User to impersonate")
+	flag.Var((*stringList)(&config.AsGroups), "as-group", "Group to impersonate, repeatable; needs --as")
+	flag.Float64Var(&config.QPS, "qps", 0, "Requests per second to the API server (default the client's, 5)")
+	flag.IntVar(&config.Burst, "burst", 0, "Requests allowed in a burst over --qps (default the client's, 10)")
+	flag.Var((*stringList)(&config.Containers), "container", "Container to read, repeatable; \"all\" reads every container (default \"application\")")
 
 	// Short flags
-	flag.StringVar(&config.Namespace, "n", "", "Kubernetes namespace")
+	flag.Var((*commaList)(&config.Namespaces), "n", "Kubernetes namespace, repeatable or comma-separated")
+	flag.BoolVar(&config.AllNamespaces, "A", false, "Read the matching pods of every namespace")
 	flag.StringVar(&config.ApplicationID, "a", "", "Application ID")
 	flag.StringVar(&config.ScopeID, "s", "", "Scope ID")
 	flag.StringVar(&config.DeploymentID, "d", "", "Deployment ID")
 	flag.IntVar(&config.Limit, "l", types.DefaultLimit, "Maximum log entries")
 	flag.StringVar(&config.NextPageToken, "t", "", "Pagination token")
-	flag.StringVar(&config.FilterPattern, "f", "", "Filter pattern")
+	flag.StringVar(&config.FilterPattern, "f", "", "Filter query")
+	flag.BoolVar(&config.IgnoreCase, "I", false, "Match the filter regardless of case")
 	flag.StringVar(&config.InstanceID, "i", "", "Instance ID")
+	flag.Var((*stringList)(&config.Containers), "c", "Container to read, repeatable")
+	flag.StringVar(&config.Output, "o", output.JSON, "Output format")
+	flag.BoolVar(&config.Follow, "F", false, "Stream new lines as newline-delimited JSON until interrupted")
+
+	flag.CommandLine.Parse(args)
 
-	flag.Parse()
+	if len(config.Containers) == 0 {
+		config.Containers = []string{types.DefaultContainerName}
+	}
+	config.TokenKey = os.Getenv(TokenKeyEnv)
 	return config
-}
\ No newline at end of file
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -13,22 +15,49 @@ import (
 	"kube-logger-go/internal/types"
 )
 
-// NewClient creates and returns a Kubernetes clientset
-func NewClient() (*kubernetes.Clientset, error) {
-	var config *rest.Config
+// NewClient creates and returns a Kubernetes clientset for the cluster, identity and rate
+// limits of the configuration
+func NewClient(config types.Config) (kubernetes.Interface, error) {
+	restConfig, err := RestConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(restConfig)
+}
+
+// RestConfig builds the client configuration. A kubeconfig or context asked for is used as
+// is; otherwise the in-cluster config is tried first, then the default kubeconfig.
+func RestConfig(config types.Config) (*rest.Config, error) {
+	var restConfig *rest.Config
 	var err error
 
-	// Try in-cluster config first
-	if config, err = rest.InClusterConfig(); err != nil {
+	if config.Kubeconfig != "" || config.Context != "" {
+		rules := clientcmd.NewDefaultClientConfigLoadingRules()
+		rules.ExplicitPath = config.Kubeconfig
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: config.Context}
+		restConfig, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+		if err != nil {
+			return nil, err
+		}
+	} else if restConfig, err = rest.InClusterConfig(); err != nil {
 		// Fall back to kubeconfig
 		kubeconfig := clientcmd.NewDefaultClientConfigLoadingRules().GetDefaultFilename()
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+		restConfig, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return kubernetes.NewForConfig(config)
+	if config.As != "" {
+		restConfig.Impersonate = rest.ImpersonationConfig{UserName: config.As, Groups: config.AsGroups}
+	}
+	if config.QPS > 0 {
+		restConfig.QPS = float32(config.QPS)
+	}
+	if config.Burst > 0 {
+		restConfig.Burst = config.Burst
+	}
+	return restConfig, nil
 }
 
 // buildLabelSelector builds a label selector string based on the config
@@ -43,29 +72,90 @@ func buildLabelSelector(config types.Config) string {
 	if config.DeploymentID != "" {
 		selector += ",deployment_id=" + config.DeploymentID
 	}
+	// The Job controller labels the pods of every execution of a scheduled task with the
+	// name and UID of its Job, whether a CronJob or a manual trigger created it
+	if config.JobName != "" {
+		selector += "," + types.JobNameLabel + "=" + config.JobName
+	}
+	if config.ExecutionID != "" {
+		selector += "," + types.JobUIDLabel + "=" + config.ExecutionID
+	}
 	return selector
 }
 
-// GetPods retrieves pods based on the configuration
-func GetPods(clientset *kubernetes.Clientset, config types.Config) ([]corev1.Pod, error) {
-	ctx := context.Background()
+// MatchesLabels reports whether a pod with the given labels is one the configuration selects,
+// for pods the API can no longer be asked about
+func MatchesLabels(podLabels map[string]string, config types.Config) bool {
+	selector, err := labels.Parse(buildLabelSelector(config))
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(podLabels))
+}
+
+// Namespaces lists the namespaces the API is asked about for a query. Every namespace is
+// metav1.NamespaceAll, which one request covers.
+func Namespaces(config types.Config) []string {
+	if config.AllNamespaces {
+		return []string{metav1.NamespaceAll}
+	}
+	return config.Namespaces
+}
+
+// GetPods retrieves pods based on the configuration, from every namespace it reads
+func GetPods(ctx context.Context, clientset kubernetes.Interface, config types.Config) ([]corev1.Pod, error) {
 	selector := buildLabelSelector(config)
 
-	podList, err := clientset.CoreV1().Pods(config.Namespace).List(ctx, metav1.ListOptions{
-		LabelSelector: selector,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to list pods: %v", err)
+	var pods []corev1.Pod
+	for _, namespace := range Namespaces(config) {
+		podList, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: selector,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods: %v", err)
+		}
+		pods = append(pods, podList.Items...)
 	}
 
-	return podList.Items, nil
+	return pods, nil
+}
+
+// GetPodsNamed finds the pods with the given name in the namespaces a query reads, whatever
+// their labels. Namespaces without one are skipped.
+func GetPodsNamed(ctx context.Context, clientset kubernetes.Interface, config types.Config, podName string) ([]corev1.Pod, error) {
+	var pods []corev1.Pod
+	for _, namespace := range Namespaces(config) {
+		if namespace != metav1.NamespaceAll {
+			pod, err := GetPod(ctx, clientset, namespace, podName)
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			pods = append(pods, *pod)
+			continue
+		}
+
+		podList, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+			FieldSelector: "metadata.name=" + podName,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods: %v", err)
+		}
+		for _, pod := range podList.Items {
+			if pod.Name == podName {
+				pods = append(pods, pod)
+			}
+		}
+	}
+	return pods, nil
 }
 
-func GetPod(clientset *kubernetes.Clientset, namespace, podName string) (*corev1.Pod, error) {
-    ctx := context.Background()
+func GetPod(ctx context.Context, clientset kubernetes.Interface, namespace, podName string) (*corev1.Pod, error) {
     pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
     if err != nil {
-        return nil, fmt.Errorf("failed to get pod %s in namespace %s: %v", podName, namespace, err)
+        return nil, fmt.Errorf("failed to get pod %s in namespace %s: %w", podName, namespace, err)
     }
     return pod, nil
 }
@@ -0,0 +1,79 @@
+package kubernetes
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"kube-logger-go/internal/types"
+)
+
+const kubeconfig = `apiVersion: v1
+kind: Config
+current-context: staging
+clusters:
+- name: staging
+  cluster: {server: "https://staging.example.com"}
+- name: production
+  cluster: {server: "https://production.example.com"}
+users:
+- name: agent
+  user: {token: "secret"}
+contexts:
+- name: staging
+  context: {cluster: staging, user: agent}
+- name: production
+  context: {cluster: production, user: agent}
+`
+
+func writeKubeconfig(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// An agent managing several clusters picks one by context rather than by switching files.
+func TestRestConfigUsesTheContextAskedFor(t *testing.T) {
+	path := writeKubeconfig(t)
+
+	current, err := RestConfig(types.Config{Kubeconfig: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chosen, err := RestConfig(types.Config{Kubeconfig: path, Context: "production"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if current.Host != "https://staging.example.com" || chosen.Host != "https://production.example.com" {
+		t.Errorf("expected the current context, then production; got %s and %s", current.Host, chosen.Host)
+	}
+	if _, err := RestConfig(types.Config{Kubeconfig: path, Context: "missing"}); err == nil {
+		t.Error("expected a context the kubeconfig does not have to be an error")
+	}
+}
+
+func TestRestConfigImpersonatesAndRateLimits(t *testing.T) {
+	restConfig, err := RestConfig(types.Config{
+		Kubeconfig: writeKubeconfig(t),
+		As:         "log-reader",
+		AsGroups:   []string{"viewers"},
+		QPS:        50,
+		Burst:      100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restConfig.Impersonate.UserName != "log-reader" || !slices.Equal(restConfig.Impersonate.Groups, []string{"viewers"}) {
+		t.Errorf("expected to impersonate log-reader in viewers, got %+v", restConfig.Impersonate)
+	}
+	if restConfig.QPS != 50 || restConfig.Burst != 100 {
+		t.Errorf("expected 50 qps with bursts of 100, got %g and %d", restConfig.QPS, restConfig.Burst)
+	}
+}
@@ -0,0 +1,263 @@
+// Package fake provides a Kubernetes API for tests that serves pods, their logs and their
+// events.
+package fake
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	clientfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	fakerest "k8s.io/client-go/rest/fake"
+)
+
+// Cluster is a kubernetes.Interface whose pods are listed, fetched and watched by the client-go
+// fake, and whose pod logs are served from the lines added with AddLogs, read the way the API
+// reads them.
+type Cluster struct {
+	*clientfake.Clientset
+
+	mu       sync.Mutex
+	logs     map[logKey][]string
+	hanging  map[string]bool
+	failures map[string]*apierrors.StatusError
+
+	eventCount int
+}
+
+// logKey identifies the log of a container instance
+type logKey struct {
+	namespace string
+	pod       string
+	container string
+	previous  bool
+}
+
+// NewCluster creates a cluster running pods
+func NewCluster(pods ...*corev1.Pod) *Cluster {
+	objects := make([]runtime.Object, 0, len(pods))
+	for _, pod := range pods {
+		objects = append(objects, pod)
+	}
+	return &Cluster{
+		Clientset: clientfake.NewClientset(objects...),
+		logs:      make(map[logKey][]string),
+		hanging:   make(map[string]bool),
+		failures:  make(map[string]*apierrors.StatusError),
+	}
+}
+
+// Pod returns a pod running the given containers, each without restarts. Its UID is its name
+// prefixed with uid-.
+func Pod(namespace, name string, labels map[string]string, containers ...string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, UID: k8stypes.UID("uid-" + name), Labels: labels},
+	}
+	for _, container := range containers {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: container})
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+			Name:  container,
+			State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+		})
+	}
+	return pod
+}
+
+// AddLogs appends lines, each a timestamp and a message as the API writes them with
+// timestamps, to the log of a container instance. previous selects the instance before the
+// last restart.
+func (c *Cluster) AddLogs(namespace, pod, container string, previous bool, lines ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := logKey{namespace: namespace, pod: pod, container: container, previous: previous}
+	c.logs[key] = append(c.logs[key], lines...)
+}
+
+// AddEvent records an event about a pod of the cluster, as the kubelet reports one, last seen
+// at the RFC3339 timestamp at. container names the container it is about, if any.
+func (c *Cluster) AddEvent(namespace, pod, container, eventType, reason, message, at string) {
+	seen, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		panic(fmt.Sprintf("event time %q is not RFC3339: %v", at, err))
+	}
+
+	involved := corev1.ObjectReference{Kind: "Pod", Namespace: namespace, Name: pod, UID: k8stypes.UID("uid-" + pod)}
+	if container != "" {
+		involved.FieldPath = "spec.containers{" + container + "}"
+	}
+	event := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Namespace: namespace},
+		InvolvedObject: involved,
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		FirstTimestamp: metav1.NewTime(seen),
+		LastTimestamp:  metav1.NewTime(seen),
+		Count:          1,
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.eventCount++
+	event.Name = fmt.Sprintf("%s.%d", pod, c.eventCount)
+	if _, err := c.Clientset.CoreV1().Events(namespace).Create(context.Background(), event, metav1.CreateOptions{}); err != nil {
+		panic(err)
+	}
+}
+
+// Hang makes the logs of a pod never arrive, like those of a pod on an unresponsive kubelet.
+// Reading them blocks until the request is cancelled.
+func (c *Cluster) Hang(namespace, pod string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hanging[namespace+"/"+pod] = true
+}
+
+// FailLogs makes reading the logs of a pod fail with err, as the API fails when access is
+// denied or the container has not started
+func (c *Cluster) FailLogs(namespace, pod string, err *apierrors.StatusError) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.failures[namespace+"/"+pod] = err
+}
+
+// failure returns the error reading the logs of a pod fails with, if any
+func (c *Cluster) failure(namespace, pod string) *apierrors.StatusError {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.failures[namespace+"/"+pod]
+}
+
+// hangs reports whether the logs of a pod never arrive
+func (c *Cluster) hangs(namespace, pod string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hanging[namespace+"/"+pod]
+}
+
+// CoreV1 returns the core client, serving pod logs from the cluster
+func (c *Cluster) CoreV1() corev1client.CoreV1Interface {
+	return &coreV1{CoreV1Interface: c.Clientset.CoreV1(), cluster: c}
+}
+
+type coreV1 struct {
+	corev1client.CoreV1Interface
+	cluster *Cluster
+}
+
+func (c *coreV1) Pods(namespace string) corev1client.PodInterface {
+	return &pods{PodInterface: c.CoreV1Interface.Pods(namespace), cluster: c.cluster, namespace: namespace}
+}
+
+type pods struct {
+	corev1client.PodInterface
+	cluster   *Cluster
+	namespace string
+}
+
+// GetLogs serves the log of a container instance. Like the API, it fails for a pod that does
+// not exist, reads from the whole second of SinceTime and cuts the log at LimitBytes, even
+// within a line. A followed log ends after the lines added so far.
+func (p *pods) GetLogs(name string, opts *corev1.PodLogOptions) *rest.Request {
+	client := &fakerest.RESTClient{
+		Client: fakerest.CreateHTTPClient(func(request *http.Request) (*http.Response, error) {
+			if _, err := p.Get(context.Background(), name, metav1.GetOptions{}); err != nil {
+				return statusResponse(err), nil
+			}
+			if err := p.cluster.failure(p.namespace, name); err != nil {
+				return statusResponse(err), nil
+			}
+			if p.cluster.hangs(p.namespace, name) {
+				return &http.Response{StatusCode: http.StatusOK, Body: hungBody{request.Context()}}, nil
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(p.cluster.read(p.namespace, name, opts)))}, nil
+		}),
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		GroupVersion:         corev1.SchemeGroupVersion,
+		VersionedAPIPath:     fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/log", p.namespace, name),
+	}
+	return client.Request()
+}
+
+// statusResponse is the response of the API failing with err, which client-go reads back
+// into an error with the same reason
+func statusResponse(err error) *http.Response {
+	var apiErr apierrors.APIStatus
+	if !errors.As(err, &apiErr) {
+		apiErr = apierrors.NewInternalError(err)
+	}
+	status := apiErr.Status()
+	status.Kind = "Status"
+	status.APIVersion = "v1"
+
+	body, _ := json.Marshal(status)
+	return &http.Response{
+		StatusCode: int(status.Code),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+}
+
+// read returns the log of a container instance as the API would send it
+func (c *Cluster) read(namespace, pod string, opts *corev1.PodLogOptions) string {
+	c.mu.Lock()
+	lines := c.logs[logKey{namespace: namespace, pod: pod, container: opts.Container, previous: opts.Previous}]
+	c.mu.Unlock()
+
+	var since time.Time
+	if opts.SinceTime != nil {
+		since = opts.SinceTime.Time.Truncate(time.Second)
+	}
+
+	var log strings.Builder
+	for _, line := range lines {
+		timestamp, message, _ := strings.Cut(line, " ")
+		if at, err := time.Parse(time.RFC3339Nano, timestamp); err == nil && at.Before(since) {
+			continue
+		}
+		if opts.Timestamps {
+			log.WriteString(line)
+		} else {
+			log.WriteString(message)
+		}
+		log.WriteString("\n")
+	}
+
+	if opts.LimitBytes != nil && int64(log.Len()) > *opts.LimitBytes {
+		return log.String()[:*opts.LimitBytes]
+	}
+	return log.String()
+}
+
+// hungBody is a response body whose data never arrives
+type hungBody struct {
+	ctx context.Context
+}
+
+func (b hungBody) Read([]byte) (int, error) {
+	<-b.ctx.Done()
+	return 0, b.ctx.Err()
+}
+
+func (b hungBody) Close() error {
+	return nil
+}
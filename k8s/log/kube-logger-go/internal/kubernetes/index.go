@@ -0,0 +1,258 @@
+package kubernetes
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"kube-logger-go/internal/types"
+)
+
+// resyncPeriod is how often the index reports every pod it holds to its event handlers again,
+// as the watches it replaces did when the API server reopened them. A follow reopens the
+// streams of running containers the API server closed on such a report.
+const resyncPeriod = time.Minute
+
+// Labels nullplatform puts on the pods of a scope, which the index looks pods up by, the most
+// specific first
+var indexedLabels = []string{"deployment_id", "scope_id", "application_id"}
+
+// PodIndex keeps the pods a configuration selects in memory, through an informer per
+// namespace it reads, indexed by their nullplatform labels. A process that answers many
+// queries, or follows one, looks pods up there instead of listing them every time, and sees
+// new pods as soon as the API server reports them.
+type PodIndex struct {
+	config    types.Config
+	informers map[string]cache.SharedIndexInformer
+	running   sync.WaitGroup
+}
+
+// NewPodIndex creates an index of the pods config selects in the namespaces it reads. Queries
+// of those namespaces that select fewer pods are answered from it too. Start fills it.
+func NewPodIndex(clientset kubernetes.Interface, config types.Config) *PodIndex {
+	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	for _, label := range indexedLabels {
+		indexers[label] = labelIndexFunc(label)
+	}
+	selectPods := func(opts *metav1.ListOptions) {
+		if config.InstanceID != "" {
+			opts.FieldSelector = "metadata.name=" + config.InstanceID
+		} else {
+			opts.LabelSelector = buildLabelSelector(config)
+		}
+	}
+
+	informers := make(map[string]cache.SharedIndexInformer)
+	for _, namespace := range Namespaces(config) {
+		informers[namespace] = coreinformers.NewFilteredPodInformer(clientset, namespace, resyncPeriod, indexers, selectPods)
+	}
+	return &PodIndex{config: config, informers: informers}
+}
+
+// labelIndexFunc indexes pods by the value of a label
+func labelIndexFunc(label string) cache.IndexFunc {
+	return func(obj any) ([]string, error) {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok {
+			return nil, nil
+		}
+		if value, ok := pod.Labels[label]; ok {
+			return []string{value}, nil
+		}
+		return nil, nil
+	}
+}
+
+// Start runs the informers until ctx is done, and returns once they have listed the pods of
+// every namespace, or ctx is done first. It fails if the first list does, such as for want
+// of permission; the informers retry later failures on their own.
+func (i *PodIndex) Start(ctx context.Context) error {
+	syncCtx, stopSync := context.WithCancel(ctx)
+	defer stopSync()
+
+	errCh := make(chan error, len(i.informers))
+	var synced []cache.InformerSynced
+	for _, informer := range i.informers {
+		informer.SetWatchErrorHandlerWithContext(func(ctx context.Context, r *cache.Reflector, err error) {
+			if !informer.HasSynced() {
+				select {
+				case errCh <- err:
+				default:
+				}
+			}
+			cache.DefaultWatchErrorHandler(ctx, r, err)
+		})
+		synced = append(synced, informer.HasSynced)
+
+		i.running.Add(1)
+		go func() {
+			defer i.running.Done()
+			informer.RunWithContext(ctx)
+		}()
+	}
+
+	syncedCh := make(chan bool, 1)
+	go func() {
+		syncedCh <- cache.WaitForCacheSync(syncCtx.Done(), synced...)
+	}()
+
+	select {
+	case <-syncedCh:
+		return nil
+	case err := <-errCh:
+		return fmt.Errorf("failed to watch pods: %v", err)
+	}
+}
+
+// Wait waits for the informers to stop once the context they were started with is done.
+// Their event handlers are no longer called after it returns.
+func (i *PodIndex) Wait() {
+	i.running.Wait()
+}
+
+// AddEventHandler calls handler as the pods of the index are added, updated and deleted,
+// starting with those it already holds. Handlers added before Start see every pod.
+func (i *PodIndex) AddEventHandler(handler cache.ResourceEventHandler) error {
+	for _, informer := range i.informers {
+		if _, err := informer.AddEventHandler(handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pods looks up the pods a query selects, as GetPods lists them. It reports false when the
+// index does not hold every pod the query may select, and a nil index holds none.
+func (i *PodIndex) Pods(config types.Config) ([]corev1.Pod, bool) {
+	if !i.covers(config) {
+		return nil, false
+	}
+	selector, err := labels.Parse(buildLabelSelector(config))
+	if err != nil {
+		return nil, false
+	}
+
+	var pods []corev1.Pod
+	for _, namespace := range Namespaces(config) {
+		var found []corev1.Pod
+		for _, pod := range i.lookup(namespace, config) {
+			if selector.Matches(labels.Set(pod.Labels)) {
+				found = append(found, *pod)
+			}
+		}
+		pods = append(pods, sortPods(found)...)
+	}
+	return pods, true
+}
+
+// PodsNamed looks up the pods with the given name, as GetPodsNamed finds them. It reports
+// false when the index does not hold such a pod, which the API may still have without the
+// labels the index selects.
+func (i *PodIndex) PodsNamed(config types.Config, podName string) ([]corev1.Pod, bool) {
+	config.InstanceID = podName
+	if !i.covers(config) {
+		return nil, false
+	}
+
+	var pods []corev1.Pod
+	for _, namespace := range Namespaces(config) {
+		var found []corev1.Pod
+		for _, pod := range i.lookup(namespace, types.Config{}) {
+			if pod.Name == podName {
+				found = append(found, *pod)
+			}
+		}
+		pods = append(pods, sortPods(found)...)
+	}
+	return pods, len(pods) > 0
+}
+
+// covers reports whether the index holds every pod a query may select: it watches the
+// namespaces of the query, and selects every pod the query does
+func (i *PodIndex) covers(config types.Config) bool {
+	if i == nil {
+		return false
+	}
+	for _, namespace := range Namespaces(config) {
+		if i.informer(namespace) == nil {
+			return false
+		}
+	}
+
+	for _, field := range [][2]string{
+		{i.config.ApplicationID, config.ApplicationID},
+		{i.config.ScopeID, config.ScopeID},
+		{i.config.DeploymentID, config.DeploymentID},
+		{i.config.JobName, config.JobName},
+		{i.config.ExecutionID, config.ExecutionID},
+		{i.config.InstanceID, config.InstanceID},
+	} {
+		if indexed, queried := field[0], field[1]; indexed != "" && indexed != queried {
+			return false
+		}
+	}
+	return true
+}
+
+// informer finds the informer holding the pods of a namespace: its own, or the one of every
+// namespace
+func (i *PodIndex) informer(namespace string) cache.SharedIndexInformer {
+	if informer, ok := i.informers[namespace]; ok {
+		return informer
+	}
+	return i.informers[metav1.NamespaceAll]
+}
+
+// lookup returns the pods of a namespace with the most specific nullplatform label a query
+// sets, or all of them
+func (i *PodIndex) lookup(namespace string, config types.Config) []*corev1.Pod {
+	indexer := i.informer(namespace).GetIndexer()
+	values := map[string]string{
+		"deployment_id":  config.DeploymentID,
+		"scope_id":       config.ScopeID,
+		"application_id": config.ApplicationID,
+	}
+
+	var objects []any
+	index := ""
+	for _, label := range indexedLabels {
+		if values[label] != "" {
+			index = label
+			break
+		}
+	}
+	if index != "" {
+		objects, _ = indexer.ByIndex(index, values[index])
+	} else if namespace != metav1.NamespaceAll {
+		objects, _ = indexer.ByIndex(cache.NamespaceIndex, namespace)
+	} else {
+		objects = indexer.List()
+	}
+
+	var pods []*corev1.Pod
+	for _, object := range objects {
+		pod, ok := object.(*corev1.Pod)
+		if ok && (namespace == metav1.NamespaceAll || pod.Namespace == namespace) {
+			pods = append(pods, pod)
+		}
+	}
+	return pods
+}
+
+// sortPods orders the pods of a namespace, or of all of them, as the API lists them
+func sortPods(pods []corev1.Pod) []corev1.Pod {
+	slices.SortFunc(pods, func(a, b corev1.Pod) int {
+		return cmp.Or(cmp.Compare(a.Namespace, b.Namespace), cmp.Compare(a.Name, b.Name))
+	})
+	return pods
+}
@@ -0,0 +1,126 @@
+package kubernetes
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"kube-logger-go/internal/kubernetes/fake"
+	"kube-logger-go/internal/types"
+)
+
+func scopeLabels(application, scope string) map[string]string {
+	return map[string]string{"nullplatform": "true", "application_id": application, "scope_id": scope}
+}
+
+func podNames(pods []corev1.Pod) []string {
+	var names []string
+	for _, pod := range pods {
+		names = append(names, pod.Namespace+"/"+pod.Name)
+	}
+	return names
+}
+
+// startIndex indexes the pods config selects until the test ends
+func startIndex(t *testing.T, cluster *fake.Cluster, config types.Config) *PodIndex {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	index := NewPodIndex(cluster, config)
+	t.Cleanup(func() {
+		cancel()
+		index.Wait()
+	})
+	if err := index.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return index
+}
+
+func TestPodIndexFindsThePodsGetPodsLists(t *testing.T) {
+	cluster := fake.NewCluster(
+		fake.Pod("ns", "app-b", scopeLabels("1", "2"), "application"),
+		fake.Pod("ns", "app-a", scopeLabels("1", "2"), "application"),
+		fake.Pod("ns", "other-scope", scopeLabels("1", "3"), "application"),
+		fake.Pod("ns", "other-app", scopeLabels("9", "2"), "application"),
+		fake.Pod("ns", "unmanaged", map[string]string{"application_id": "1", "scope_id": "2"}, "application"),
+		fake.Pod("elsewhere", "app-c", scopeLabels("1", "2"), "application"),
+	)
+	index := startIndex(t, cluster, types.Config{AllNamespaces: true})
+
+	for name, query := range map[string]types.Config{
+		"scope":       {Namespaces: []string{"ns"}, ApplicationID: "1", ScopeID: "2"},
+		"application": {Namespaces: []string{"ns", "elsewhere"}, ApplicationID: "1"},
+		"everywhere":  {AllNamespaces: true, ScopeID: "2"},
+	} {
+		listed, err := GetPods(context.Background(), cluster, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		indexed, ok := index.Pods(query)
+
+		if !ok || !slices.Equal(podNames(indexed), podNames(listed)) {
+			t.Errorf("%s: expected the listed pods %q, got %q (%t)", name, podNames(listed), podNames(indexed), ok)
+		}
+	}
+}
+
+// A query outside the index is listed instead, rather than answered with fewer pods.
+func TestPodIndexOnlyAnswersQueriesItHoldsEveryPodOf(t *testing.T) {
+	cluster := fake.NewCluster(fake.Pod("ns", "app-a", scopeLabels("1", "2"), "application"))
+	index := startIndex(t, cluster, types.Config{Namespaces: []string{"ns"}, ApplicationID: "1", ScopeID: "2"})
+
+	if _, ok := index.Pods(types.Config{Namespaces: []string{"ns"}, ApplicationID: "1", ScopeID: "2", DeploymentID: "3"}); !ok {
+		t.Error("expected a narrower query to be answered")
+	}
+	for name, query := range map[string]types.Config{
+		"other namespace": {Namespaces: []string{"elsewhere"}, ApplicationID: "1", ScopeID: "2"},
+		"every namespace": {AllNamespaces: true, ApplicationID: "1", ScopeID: "2"},
+		"other scope":     {Namespaces: []string{"ns"}, ApplicationID: "1", ScopeID: "3"},
+		"whole app":       {Namespaces: []string{"ns"}, ApplicationID: "1"},
+	} {
+		if pods, ok := index.Pods(query); ok {
+			t.Errorf("%s: expected to be listed, got %q from the index", name, podNames(pods))
+		}
+	}
+
+	var missing *PodIndex
+	if _, ok := missing.Pods(types.Config{Namespaces: []string{"ns"}}); ok {
+		t.Error("expected no index to answer nothing")
+	}
+}
+
+func TestPodIndexSeesNewPods(t *testing.T) {
+	cluster := fake.NewCluster()
+	query := types.Config{Namespaces: []string{"ns"}, ApplicationID: "1"}
+	index := startIndex(t, cluster, types.Config{Namespaces: []string{"ns"}})
+
+	pod := fake.Pod("ns", "app-a", scopeLabels("1", "2"), "application")
+	if _, err := cluster.CoreV1().Pods("ns").Create(context.Background(), pod, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pods, _ := index.Pods(query)
+		if slices.Equal(podNames(pods), []string{"ns/app-a"}) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the new pod in the index, got %q", podNames(pods))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	named, ok := index.PodsNamed(types.Config{Namespaces: []string{"ns"}}, "app-a")
+	if !ok || len(named) != 1 || named[0].UID != pod.UID {
+		t.Errorf("expected the new pod by its name, got %q (%t)", podNames(named), ok)
+	}
+	if _, ok := index.PodsNamed(types.Config{Namespaces: []string{"ns"}}, "unlabelled"); ok {
+		t.Error("expected a pod the index does not hold to be looked up in the API")
+	}
+}
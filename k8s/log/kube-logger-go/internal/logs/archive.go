@@ -0,0 +1,140 @@
+package logs
+
+import (
+	"bufio"
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"kube-logger-go/internal/archive"
+	"kube-logger-go/internal/kubernetes"
+	"kube-logger-go/internal/types"
+)
+
+// ArchiveSource reads the logs of pods that no longer run, such as the ones a finished
+// blue-green deployment removed, from an archive they were persisted to
+type ArchiveSource struct {
+	store archive.Store
+}
+
+// NewArchiveSource creates a source reading from store
+func NewArchiveSource(store archive.Store) *ArchiveSource {
+	return &ArchiveSource{store: store}
+}
+
+// Targets lists the archived instances of the selected containers of the matching pods
+func (s *ArchiveSource) Targets(ctx context.Context, config types.Config) ([]Target, error) {
+	var pods []archive.Pod
+	for _, namespace := range kubernetes.Namespaces(config) {
+		found, err := archive.ListPods(ctx, s.store, namespace)
+		if err != nil {
+			return nil, err
+		}
+		pods = append(pods, found...)
+	}
+
+	var targets []Target
+	for _, pod := range pods {
+		// As with the API, an instance asked for by name is read whatever its labels.
+		if config.InstanceID != "" {
+			if pod.Name != config.InstanceID {
+				continue
+			}
+		} else if !kubernetes.MatchesLabels(pod.Labels, config) {
+			continue
+		}
+		targets = append(targets, archivedTargets(pod, config)...)
+	}
+	return targets, nil
+}
+
+// archivedTargets expands an archived pod into the streams read from it: the last instance
+// of every selected container and, unless the previous mode is never, the ones before it.
+// Every archived instance has ended, so auto reads them all and the window sorts them out.
+func archivedTargets(pod archive.Pod, config types.Config) []Target {
+	info := podInfo(pod.Name, pod.UID, pod.Namespace, config)
+
+	var available []string
+	for _, log := range pod.Logs {
+		if !slices.Contains(available, log.Container) {
+			available = append(available, log.Container)
+		}
+	}
+
+	var targets []Target
+	for _, container := range selectContainers(available, config.Containers) {
+		var restarts []int
+		for _, log := range pod.Logs {
+			if log.Container == container {
+				restarts = append(restarts, log.Restart)
+			}
+		}
+
+		last := len(restarts) - 1
+		var metadata *types.Metadata
+		if config.IncludeMetadata {
+			metadata = labelMetadata(pod.Labels, restarts[last])
+		}
+		for i, restart := range restarts {
+			if i < last && config.Previous == types.PreviousNever {
+				continue
+			}
+			targets = append(targets, Target{Namespace: pod.Namespace, Pod: info, Container: container, Restart: restart, Previous: i < last, Finished: true, Metadata: metadata})
+		}
+	}
+	return targets
+}
+
+// Stream sends the lines of an archived instance from sinceTime on. Like the API, it reads
+// from the whole second of sinceTime and cuts the stream at exactly limitBytes, so the
+// fetcher tells a read cut short from one that reached the end.
+func (s *ArchiveSource) Stream(ctx context.Context, target Target, sinceTime string, limitBytes int64, logCh chan<- string) error {
+	file, err := s.store.Open(ctx, archive.LogKey(target.Namespace, target.Pod.ID, target.Container, target.Restart))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var since time.Time
+	if sinceTime != "" {
+		if sinceTimeObj, err := time.Parse(time.RFC3339, sinceTime); err == nil {
+			since = sinceTimeObj.Truncate(time.Second)
+		}
+	}
+
+	var read int64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if !since.IsZero() {
+			timestamp, _, _ := strings.Cut(line, " ")
+			if at, err := time.Parse(time.RFC3339Nano, timestamp); err == nil && at.Before(since) {
+				continue
+			}
+		}
+
+		read += int64(len(line)) + 1
+		line, cut := limitLine(line, read, limitBytes)
+
+		select {
+		case logCh <- line:
+		case <-ctx.Done():
+			return nil
+		}
+		if cut {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// limitLine cuts the line that brings a read to read bytes, with its line break, where the
+// read reaches limitBytes, as the API does. It reports whether the read ends with it.
+func limitLine(line string, read, limitBytes int64) (string, bool) {
+	if limitBytes <= 0 || read < limitBytes {
+		return line, false
+	}
+	return line[:min(int64(len(line)), int64(len(line))+1-(read-limitBytes))], true
+}
@@ -0,0 +1,113 @@
+package logs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"kube-logger-go/internal/archive"
+	"kube-logger-go/internal/types"
+)
+
+// archiveWith creates a source reading an archive directory of the given files, by key
+func archiveWith(t *testing.T, files map[string]string) *ArchiveSource {
+	t.Helper()
+
+	root := t.TempDir()
+	for key, content := range files {
+		name := filepath.Join(root, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, err := archive.NewDirStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewArchiveSource(store)
+}
+
+const scopePod = `{"name": "app-a", "labels": {"nullplatform": "true", "application_id": "1", "scope_id": "2"}}`
+
+func TestArchiveSourceSelectsPodsLikeTheAPI(t *testing.T) {
+	source := archiveWith(t, map[string]string{
+		"ns/a/pod.json":          scopePod,
+		"ns/a/application/0.log": "",
+		"ns/b/pod.json":          `{"name": "app-b", "labels": {"nullplatform": "true", "application_id": "1", "scope_id": "3"}}`,
+		"ns/b/application/0.log": "",
+	})
+
+	targets, err := source.Targets(context.Background(), types.Config{Namespaces: []string{"ns"}, ApplicationID: "1", ScopeID: "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 || targets[0].Pod != (types.PodInfo{Name: "app-a", ID: "a"}) {
+		t.Errorf("expected only the pod of scope 2, got %+v", targets)
+	}
+
+	targets, _ = source.Targets(context.Background(), types.Config{Namespaces: []string{"ns"}, InstanceID: "app-b"})
+	if len(targets) != 1 || targets[0].Pod.ID != "b" {
+		t.Errorf("expected the instance asked for by name, got %+v", targets)
+	}
+}
+
+func TestArchiveSourceReadsEarlierInstancesUnlessPreviousIsNever(t *testing.T) {
+	source := archiveWith(t, map[string]string{
+		"ns/a/pod.json":          scopePod,
+		"ns/a/application/0.log": "",
+		"ns/a/application/1.log": "",
+		"ns/a/istio-proxy/0.log": "",
+	})
+	config := types.Config{Namespaces: []string{"ns"}, Containers: []string{types.DefaultContainerName}}
+
+	targets, _ := source.Targets(context.Background(), config)
+	if len(targets) != 1 || targets[0].Restart != 1 || targets[0].Previous {
+		t.Errorf("expected only the last instance, got %+v", targets)
+	}
+
+	config.Previous = types.PreviousAuto
+	targets, _ = source.Targets(context.Background(), config)
+	if len(targets) != 2 || !targets[0].Previous || targets[0].Restart != 0 || targets[1].Restart != 1 {
+		t.Errorf("expected both instances, got %+v", targets)
+	}
+}
+
+// The API reads from the whole second of since_time; pagination relies on it.
+func TestArchiveSourceStreamsFromTheSecondOfSinceTime(t *testing.T) {
+	source := archiveWith(t, map[string]string{
+		"ns/a/application/0.log": "2026-08-17T10:00:00.500000000Z before\n" +
+			"2026-08-17T10:00:01.000000000Z first\n" +
+			"2026-08-17T10:00:01.500000000Z second\n" +
+			"2026-08-17T10:00:02.000000000Z third\n",
+	})
+	target := Target{Namespace: "ns", Pod: types.PodInfo{ID: "a"}, Container: types.DefaultContainerName}
+
+	read := func(limitBytes int64) []string {
+		logCh := make(chan string, 10)
+		if err := source.Stream(context.Background(), target, "2026-08-17T10:00:01.5Z", limitBytes, logCh); err != nil {
+			t.Fatal(err)
+		}
+		close(logCh)
+
+		var lines []string
+		for line := range logCh {
+			lines = append(lines, line)
+		}
+		return lines
+	}
+
+	if got := read(0); !slices.Equal(got, []string{"2026-08-17T10:00:01.000000000Z first", "2026-08-17T10:00:01.500000000Z second", "2026-08-17T10:00:02.000000000Z third"}) {
+		t.Errorf("expected the lines from 10:00:01 on, got %q", got)
+	}
+	// Like the API, the limit cuts the line it falls in, so a read cut short is told apart
+	// from one that reached the end
+	if got := read(80); !slices.Equal(got, []string{"2026-08-17T10:00:01.000000000Z first", "2026-08-17T10:00:01.500000000Z second", "2026-"}) {
+		t.Errorf("expected the lines cut at 80 bytes, got %q", got)
+	}
+}
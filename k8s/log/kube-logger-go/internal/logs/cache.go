@@ -0,0 +1,82 @@
+package logs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"kube-logger-go/internal/cache"
+	"kube-logger-go/internal/types"
+)
+
+// CachedSource reads through another source, keeping the reads that can no longer change in
+// a cache. A read cut by its byte limit always returns the same lines, as the lines after
+// them do not change what came first, and so does any read of an instance that has finished.
+// Reads that reached the end of a running container are not kept, as it may still write, and
+// neither are events, which are observed again and reordered by when they last were.
+type CachedSource struct {
+	source LogSource
+	cache  *cache.Cache
+}
+
+// NewCachedSource creates a source reading source through cache
+func NewCachedSource(source LogSource, cache *cache.Cache) *CachedSource {
+	return &CachedSource{source: source, cache: cache}
+}
+
+// Targets lists the targets of the source it reads through
+func (s *CachedSource) Targets(ctx context.Context, config types.Config) ([]Target, error) {
+	return s.source.Targets(ctx, config)
+}
+
+// Stream sends the cached lines of the read, or reads them from the source and caches them
+// when they can no longer change
+func (s *CachedSource) Stream(ctx context.Context, target Target, sinceTime string, limitBytes int64, logCh chan<- string) error {
+	if target.Container == types.EventsContainer {
+		return s.source.Stream(ctx, target, sinceTime, limitBytes, logCh)
+	}
+
+	key := cacheKey(target, sinceTime, limitBytes)
+	if data, ok := s.cache.Get(key); ok {
+		for line := range strings.Lines(string(data)) {
+			select {
+			case logCh <- strings.TrimSuffix(line, "\n"):
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		return nil
+	}
+
+	var read bytes.Buffer
+	sourceCh := make(chan string)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(sourceCh)
+		errCh <- s.source.Stream(ctx, target, sinceTime, limitBytes, sourceCh)
+	}()
+
+	for line := range sourceCh {
+		read.WriteString(line)
+		read.WriteByte('\n')
+		select {
+		case logCh <- line:
+		case <-ctx.Done():
+		}
+	}
+	if err := <-errCh; err != nil || ctx.Err() != nil {
+		return err
+	}
+
+	// Failing to cache a read is no reason to fail it
+	if target.Finished || limitBytes > 0 && int64(read.Len()) >= limitBytes {
+		s.cache.Put(key, read.Bytes())
+	}
+	return nil
+}
+
+// cacheKey identifies a read of a container instance
+func cacheKey(target Target, sinceTime string, limitBytes int64) string {
+	return fmt.Sprintf("%s/%s/%s/%d/%t/%s/%d", target.Namespace, target.Pod.ID, target.Container, target.Restart, target.Previous, sinceTime, limitBytes)
+}
@@ -0,0 +1,110 @@
+package logs
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"kube-logger-go/internal/cache"
+	"kube-logger-go/internal/types"
+)
+
+// apiSource serves one log the way the API does, cut at exactly limitBytes, mid-line if need be
+type apiSource struct {
+	log   string
+	reads int
+}
+
+func (s *apiSource) Targets(ctx context.Context, config types.Config) ([]Target, error) {
+	return nil, nil
+}
+
+func (s *apiSource) Stream(ctx context.Context, target Target, sinceTime string, limitBytes int64, logCh chan<- string) error {
+	s.reads++
+	log := s.log
+	if limitBytes > 0 && int64(len(log)) > limitBytes {
+		log = log[:limitBytes]
+	}
+	for line := range strings.Lines(log) {
+		logCh <- strings.TrimSuffix(line, "\n")
+	}
+	return nil
+}
+
+// readTwice reads the same lines of target twice through a cache and returns what the second
+// read got
+func readTwice(t *testing.T, source *apiSource, target Target, limitBytes int64) []string {
+	t.Helper()
+
+	logCache, err := cache.Open(t.TempDir(), time.Hour, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached := NewCachedSource(source, logCache)
+
+	var lines []string
+	for range 2 {
+		logCh := make(chan string, 10)
+		if err := cached.Stream(context.Background(), target, "2026-08-17T10:00:00Z", limitBytes, logCh); err != nil {
+			t.Fatal(err)
+		}
+		close(logCh)
+
+		lines = nil
+		for line := range logCh {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+const cachedLog = "2026-08-17T10:00:01.000000000Z first\n2026-08-17T10:00:02.000000000Z second\n"
+
+func TestCachedSourceKeepsReadsCutByTheirLimit(t *testing.T) {
+	source := &apiSource{log: cachedLog}
+
+	lines := readTwice(t, source, podA, 40)
+
+	if source.reads != 1 || !slices.Equal(lines, []string{"2026-08-17T10:00:01.000000000Z first", "202"}) {
+		t.Errorf("expected the cut read served from the cache as it was, got %q after %d reads", lines, source.reads)
+	}
+}
+
+// A running container may write more after the end of its log.
+func TestCachedSourceReadsTheEndOfARunningContainerAgain(t *testing.T) {
+	source := &apiSource{log: cachedLog}
+
+	readTwice(t, source, podA, 1000)
+
+	if source.reads != 2 {
+		t.Errorf("expected both reads from the source, got %d", source.reads)
+	}
+}
+
+func TestCachedSourceKeepsWholeReadsOfFinishedInstances(t *testing.T) {
+	source := &apiSource{log: cachedLog}
+	finished := podA
+	finished.Finished = true
+
+	lines := readTwice(t, source, finished, 0)
+
+	if source.reads != 1 || len(lines) != 2 {
+		t.Errorf("expected the finished instance read once, got %q after %d reads", lines, source.reads)
+	}
+}
+
+// Events are observed again after they are read, which moves them in the stream.
+func TestCachedSourceReadsEventsAgain(t *testing.T) {
+	source := &apiSource{log: cachedLog}
+	events := podA
+	events.Container = types.EventsContainer
+	events.Finished = true
+
+	readTwice(t, source, events, 40)
+
+	if source.reads != 2 {
+		t.Errorf("expected both reads of the events from the source, got %d", source.reads)
+	}
+}
@@ -0,0 +1,185 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"kube-logger-go/internal/types"
+)
+
+// eventTimeLayout is the fixed-width format the kubelet writes timestamps in, which cursors
+// rely on to order as strings
+const eventTimeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// eventLine is an event, or a container termination, as a line of the events stream
+type eventLine struct {
+	at   time.Time
+	line string
+}
+
+// eventsTarget is the stream of the events of a pod
+func eventsTarget(pod *corev1.Pod, config types.Config) Target {
+	var metadata *types.Metadata
+	if config.IncludeMetadata {
+		metadata = labelMetadata(pod.Labels, 0)
+		metadata.Node = pod.Spec.NodeName
+	}
+	return Target{
+		Namespace: pod.Namespace,
+		Pod:       podInfo(pod.Name, string(pod.UID), pod.Namespace, config),
+		Container: types.EventsContainer,
+		Metadata:  metadata,
+	}
+}
+
+// streamEvents sends the events of a pod, and the failed terminations its container statuses
+// report, in time order. An OOM kill has no event of its own, only the status of the
+// container it killed. Every line is logfmt, so the filter matches the reason and the level
+// as fields. Like the logs API, it reads from the whole second of sinceTime and cuts the
+// stream at exactly limitBytes.
+func (s *KubernetesSource) streamEvents(ctx context.Context, target Target, sinceTime string, limitBytes int64, logCh chan<- string) error {
+	events, err := s.clientset.CoreV1().Events(target.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: "involvedObject.uid=" + target.Pod.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	pod, err := s.clientset.CoreV1().Pods(target.Namespace).Get(ctx, target.Pod.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		pod = nil
+	} else if err != nil {
+		return err
+	}
+
+	var since time.Time
+	if sinceTimeObj, err := time.Parse(time.RFC3339, sinceTime); err == nil {
+		since = sinceTimeObj.Truncate(time.Second)
+	}
+
+	var read int64
+	for _, event := range eventLines(events.Items, pod, target.Pod.ID) {
+		if event.at.Before(since) {
+			continue
+		}
+
+		read += int64(len(event.line)) + 1
+		line, cut := limitLine(event.line, read, limitBytes)
+
+		select {
+		case logCh <- line:
+		case <-ctx.Done():
+			return nil
+		}
+		if cut {
+			return nil
+		}
+	}
+	return nil
+}
+
+// eventLines turns the events about a pod and its failed terminations into lines, oldest
+// first
+func eventLines(events []corev1.Event, pod *corev1.Pod, podUID string) []eventLine {
+	var lines []eventLine
+	for i := range events {
+		event := &events[i]
+		if string(event.InvolvedObject.UID) != podUID {
+			continue
+		}
+
+		at := eventTime(event)
+		level := "info"
+		if event.Type == corev1.EventTypeWarning {
+			level = "warn"
+		}
+		fields := []string{"level=" + level, "type=" + event.Type, "reason=" + event.Reason}
+		if container := fieldPathContainer(event.InvolvedObject.FieldPath); container != "" {
+			fields = append(fields, "object="+container)
+		}
+		if count := eventCount(event); count > 1 {
+			fields = append(fields, "count="+strconv.Itoa(int(count)))
+		}
+		fields = append(fields, "msg="+strconv.Quote(event.Message))
+		lines = append(lines, eventLine{at: at, line: at.UTC().Format(eventTimeLayout) + " " + strings.Join(fields, " ")})
+	}
+
+	if pod != nil {
+		for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+			for _, status := range statuses {
+				for _, terminated := range []*corev1.ContainerStateTerminated{status.LastTerminationState.Terminated, status.State.Terminated} {
+					if terminated == nil || terminated.ExitCode == 0 || terminated.FinishedAt.IsZero() {
+						continue
+					}
+					lines = append(lines, terminationLine(status.Name, terminated))
+				}
+			}
+		}
+	}
+
+	slices.SortStableFunc(lines, func(a, b eventLine) int { return a.at.Compare(b.at) })
+	return lines
+}
+
+// terminationLine is the line of a container that stopped with a failure
+func terminationLine(container string, terminated *corev1.ContainerStateTerminated) eventLine {
+	reason := terminated.Reason
+	if reason == "" {
+		reason = "Error"
+	}
+	message := terminated.Message
+	if message == "" {
+		message = fmt.Sprintf("Container %s terminated with exit code %d", container, terminated.ExitCode)
+	}
+
+	at := terminated.FinishedAt.Time
+	fields := []string{
+		"level=error", "type=Termination", "reason=" + reason, "object=" + container,
+		"exit_code=" + strconv.Itoa(int(terminated.ExitCode)), "msg=" + strconv.Quote(message),
+	}
+	return eventLine{at: at, line: at.UTC().Format(eventTimeLayout) + " " + strings.Join(fields, " ")}
+}
+
+// eventTime is when an event last happened. Events written through the newer events API
+// only set EventTime and their series; the older ones only the timestamps.
+func eventTime(event *corev1.Event) time.Time {
+	switch {
+	case event.Series != nil && !event.Series.LastObservedTime.IsZero():
+		return event.Series.LastObservedTime.Time
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	case !event.FirstTimestamp.IsZero():
+		return event.FirstTimestamp.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
+
+// eventCount is how often an event happened
+func eventCount(event *corev1.Event) int32 {
+	if event.Series != nil {
+		return event.Series.Count
+	}
+	return event.Count
+}
+
+// fieldPathContainer finds the container an event is about in the field path of its object,
+// such as spec.containers{application}
+func fieldPathContainer(fieldPath string) string {
+	_, rest, found := strings.Cut(fieldPath, "{")
+	if !found {
+		return ""
+	}
+	container, _, _ := strings.Cut(rest, "}")
+	return container
+}
@@ -0,0 +1,60 @@
+package logs
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEventLinesInterleaveEventsAndFailedTerminationsInTimeOrder(t *testing.T) {
+	at := func(second int) metav1.Time {
+		return metav1.NewTime(time.Date(2026, 8, 17, 10, 0, second, 0, time.UTC))
+	}
+	events := []corev1.Event{
+		{
+			InvolvedObject: corev1.ObjectReference{UID: "uid-a", FieldPath: "spec.containers{application}"},
+			Type:           corev1.EventTypeWarning, Reason: "Unhealthy", Message: `Readiness probe failed: HTTP probe failed with statuscode: 503`,
+			LastTimestamp: at(3), Count: 4,
+		},
+		{InvolvedObject: corev1.ObjectReference{UID: "uid-a"}, Type: corev1.EventTypeNormal, Reason: "Scheduled", Message: "Successfully assigned ns/app-a", LastTimestamp: at(1)},
+		{InvolvedObject: corev1.ObjectReference{UID: "uid-b"}, Type: corev1.EventTypeNormal, Reason: "Scheduled", LastTimestamp: at(1)},
+	}
+	pod := podWith(nil, "application")
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:                 "application",
+		LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137, FinishedAt: at(2)}},
+		State:                corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Completed", FinishedAt: at(5)}},
+	}}
+
+	var got []string
+	for _, line := range eventLines(events, pod, "uid-a") {
+		got = append(got, line.line)
+	}
+
+	want := []string{
+		`2026-08-17T10:00:01.000000000Z level=info type=Normal reason=Scheduled msg="Successfully assigned ns/app-a"`,
+		`2026-08-17T10:00:02.000000000Z level=error type=Termination reason=OOMKilled object=application exit_code=137 msg="Container application terminated with exit code 137"`,
+		`2026-08-17T10:00:03.000000000Z level=warn type=Warning reason=Unhealthy object=application count=4 msg="Readiness probe failed: HTTP probe failed with statuscode: 503"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected the pod's events and its OOM kill in time order:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+// Lines of events are logfmt, so the filter matches their reason and level as fields.
+func TestEventLinesAreStructured(t *testing.T) {
+	events := []corev1.Event{{
+		InvolvedObject: corev1.ObjectReference{UID: "uid-a"},
+		Type:           corev1.EventTypeWarning, Reason: "Failed", Message: `Failed to pull image "app:v2": not found`,
+		LastTimestamp: metav1.NewTime(time.Date(2026, 8, 17, 10, 0, 1, 0, time.UTC)),
+	}}
+	line := eventLines(events, nil, "uid-a")[0].line
+
+	entry := structured(line[strings.IndexByte(line, ' ')+1:])
+	if entry.Level != "warn" || !mustCompileFilter(t, "reason=Failed").Match(entry) {
+		t.Errorf("expected a warning with its reason as a field, got %+v", entry)
+	}
+}
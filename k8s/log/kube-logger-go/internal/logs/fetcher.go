@@ -1,163 +1,530 @@
 package logs
 
 import (
-	"bufio"
+	"cmp"
 	"context"
+	"errors"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
 	"kube-logger-go/internal/pagination"
 	"kube-logger-go/internal/types"
 )
 
+// Target is one container instance of a pod whose logs are read, in Namespace. Previous
+// selects the instance that ran before the last restart, whose generation is Restart.
+// Finished tells the instance has stopped, so its log no longer grows. Metadata is attached
+// to its entries when asked for.
+type Target struct {
+	Namespace string
+	Pod       types.PodInfo
+	Container string
+	Restart   int
+	Previous  bool
+	Finished  bool
+	Metadata  *types.Metadata
+}
+
 // Fetcher handles log fetching operations
 type Fetcher struct {
-	clientset *kubernetes.Clientset
+	sources   []LogSource
+	processor *Processor
 }
 
-// NewFetcher creates a new log fetcher instance
-func NewFetcher(clientset *kubernetes.Clientset) *Fetcher {
+// NewFetcher creates a new log fetcher instance reading from sources. A pod found by more
+// than one source is read from the first.
+func NewFetcher(processor *Processor, sources ...LogSource) *Fetcher {
 	return &Fetcher{
-		clientset: clientset,
+		sources:   sources,
+		processor: processor,
 	}
 }
 
-// FetchConcurrently fetches logs from multiple pods concurrently
-func (f *Fetcher) FetchConcurrently(pods []corev1.Pod, config types.Config) []types.LogEntry {
-	if len(pods) == 0 {
-		return []types.LogEntry{}
+// sourcedTarget is a target and the source its logs are read from
+type sourcedTarget struct {
+	Target
+	source LogSource
+}
+
+// targets lists the targets of every source, leaving out pods an earlier source has
+func (f *Fetcher) targets(ctx context.Context, config types.Config) ([]sourcedTarget, error) {
+	var targets []sourcedTarget
+	claimed := make(map[string]bool)
+
+	for _, source := range f.sources {
+		found, err := source.Targets(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+
+		listed := make(map[string]bool)
+		for _, t := range found {
+			if claimed[t.Pod.ID] {
+				continue
+			}
+			listed[t.Pod.ID] = true
+			targets = append(targets, sourcedTarget{Target: t, source: source})
+		}
+		for podUID := range listed {
+			claimed[podUID] = true
+		}
+	}
+	return targets, nil
+}
+
+const (
+	// initialLineBytes is the size a line is assumed to have before any was read
+	initialLineBytes = 3072
+	// maxLimitBytes caps the bytes read from a single stream for a page
+	maxLimitBytes = 8 << 20
+	// maxReadAttempts caps how often a stream cut short by its byte limit is read again
+	maxReadAttempts = 3
+)
+
+// Report lists the streams a read could not use
+type Report struct {
+	// TimedOut lists the pods with a stream that did not finish in time. What was read of
+	// those streams is left out, so the next page reads them again from their cursor.
+	TimedOut []types.PodInfo
+	// Warnings lists the streams that failed to read
+	Warnings []types.Warning
+
+	streams int
+}
+
+// AllFailed reports whether there were streams to read and every one of them failed
+func (r Report) AllFailed() bool {
+	return r.streams > 0 && len(r.Warnings) == r.streams
+}
+
+// failed records a stream that timed out or failed, and reports whether it did
+func (r *Report) failed(t Target, timedOut bool, err error) bool {
+	switch {
+	case timedOut:
+		if !slices.Contains(r.TimedOut, t.Pod) {
+			r.TimedOut = append(r.TimedOut, t.Pod)
+		}
+	case err != nil:
+		r.Warnings = append(r.Warnings, streamWarning(t, err))
+	default:
+		return false
 	}
+	return true
+}
+
+// sort puts the lists in order, as workers finish in any; the response lists pods the same
+// way every time
+func (r *Report) sort() {
+	slices.SortFunc(r.TimedOut, func(a, b types.PodInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	slices.SortFunc(r.Warnings, func(a, b types.Warning) int {
+		return cmp.Or(strings.Compare(a.Pod.Name, b.Pod.Name), strings.Compare(a.Container, b.Container), cmp.Compare(a.Restart, b.Restart))
+	})
+}
 
-	// Calculate logs per pod
-	podLimit := config.Limit / len(pods)
+// FetchResult is what a fetch read
+type FetchResult struct {
+	Entries []types.LogEntry
+	Report
+	// Progress holds the cursors of streams cut short by their byte limit that read lines
+	// without keeping any, so the next page does not filter the same lines again
+	Progress map[string]string
+}
+
+// Unfinished reports whether streams have lines left to read even if no entry was kept, so
+// an empty page must not end pagination
+func (r FetchResult) Unfinished() bool {
+	return len(r.TimedOut) > 0 || len(r.Progress) > 0
+}
+
+// targetRead is what was read of a stream for the page
+type targetRead struct {
+	entries  []types.LogEntry
+	timedOut bool
+	err      error
+	// horizon is where a stream cut short by its byte limit stops being known; zero when the
+	// stream was read to its end or the end of the window
+	horizon pagination.Position
+	// progress is the position the stream was read up to, when it was cut short
+	progress pagination.Position
+}
+
+// streamWarning describes a stream that failed, by the reason the API gave for it
+func streamWarning(t Target, err error) types.Warning {
+	reason := string(apierrors.ReasonForError(err))
+	if reason == "" {
+		reason = "StreamFailed"
+	}
+	return types.Warning{Pod: t.Pod, Container: t.Container, Restart: t.Restart, Reason: reason, Message: err.Error()}
+}
+
+// FetchConcurrently fetches logs from the selected containers of every pod, resuming each
+// stream at its cursor from the decoded pagination token. At most config.Parallelism streams
+// are read at once, each for at most config.PodTimeout; zero leaves either unbounded. Streams
+// still unread when ctx is done time out.
+func (f *Fetcher) FetchConcurrently(ctx context.Context, lastReadTimes map[string]string, config types.Config) (FetchResult, error) {
+	targets, err := f.targets(ctx, config)
+	if err != nil {
+		return FetchResult{}, err
+	}
+
+	if len(targets) == 0 {
+		return FetchResult{Entries: []types.LogEntry{}}, nil
+	}
+
+	// Calculate logs per container
+	podLimit := config.Limit / len(targets)
 	if podLimit < types.MinLogsPerPod {
 		podLimit = types.MinLogsPerPod
 	}
 
-	// Decode pagination token
-	lastReadTimes := pagination.DecodeToken(config.NextPageToken)
+	backward := pagination.TokenDirection(lastReadTimes, config.Direction) == types.DirectionBackward
 
-	allLogs := make([]types.LogEntry, 0, config.Limit)
+	result := FetchResult{Entries: make([]types.LogEntry, 0, config.Limit), Report: Report{streams: len(targets)}, Progress: make(map[string]string)}
+	var horizon pagination.Position
 	var mu sync.Mutex
-	var wg sync.WaitGroup
 
-	// Fetch logs from each pod concurrently
-	for _, pod := range pods {
-		wg.Add(1)
-		go func(p corev1.Pod) {
-			defer wg.Done()
+	eachTarget(targets, config.Parallelism, func(t sourcedTarget) {
+		read := f.fetchTarget(ctx, t, lastReadTimes, config, podLimit, backward)
 
-			podUID := string(p.UID)
+		mu.Lock()
+		defer mu.Unlock()
+		if result.failed(t.Target, read.timedOut, read.err) {
+			return
+		}
+		result.Entries = append(result.Entries, read.entries...)
+		if !read.horizon.IsZero() && (horizon.IsZero() || read.horizon.Time < horizon.Time) {
+			horizon = read.horizon
+		}
+		if !read.progress.IsZero() && len(read.entries) == 0 {
+			result.Progress[pagination.CursorKey(t.Pod, t.Container, t.Restart)] = read.progress.String()
+		}
+	})
 
-			// Determine since time for this pod
-			sinceTime := determineSinceTime(podUID, lastReadTimes, config.StartTime)
+	// A stream cut short may have lines older than the ones other streams read past its cut,
+	// so the page stops at the earliest cut to stay a time-ordered prefix of every stream.
+	if !horizon.IsZero() {
+		result.Entries = slices.DeleteFunc(result.Entries, func(entry types.LogEntry) bool {
+			return entry.DateTime > horizon.Time
+		})
+	}
 
-			// Cancelling releases the producer when the processor stops at the end of the window.
-            ctx, cancel := context.WithCancel(context.Background())
-            defer cancel()
+	result.sort()
+	return result, nil
+}
 
-            logCh := make(chan string, 100)
-            go func() {
-                defer close(logCh)
-                f.streamPodLogs(ctx, &p, config.Namespace, sinceTime, int64(podLimit*3072), logCh)
-            }()
+// eachTarget calls read for every target from a pool of at most parallelism workers, zero
+// leaving it unbounded, and returns once all are read
+func eachTarget(targets []sourcedTarget, parallelism int, read func(sourcedTarget)) {
+	workers := parallelism
+	if workers <= 0 || workers > len(targets) {
+		workers = len(targets)
+	}
 
-            processor := NewProcessor()
-            processedLogs := processor.ProcessLinesFromChannel(logCh, config.FilterPattern, p.Name, podUID, getLastReadTime(podUID, lastReadTimes), config.EndTime)
+	var wg sync.WaitGroup
+	work := make(chan sourcedTarget)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range work {
+				read(t)
+			}
+		}()
+	}
 
-            if len(processedLogs) > 0 {
-                mu.Lock()
-                allLogs = append(allLogs, processedLogs...)
-                mu.Unlock()
-            }
-		}(pod)
+	for _, target := range targets {
+		work <- target
 	}
+	close(work)
 
 	wg.Wait()
-	return allLogs
 }
 
-// getPodLogs retrieves logs from a specific pod
-func (f *Fetcher) getPodLogs(pod *corev1.Pod, namespace, sinceTime string, limitBytes int64) string {
-	ctx := context.Background()
+// fetchTarget reads the entries of one stream for the page. A stream that ran out of time is
+// reported without its entries, which are incomplete: a stream read backward only keeps the
+// newest of the lines it got to.
+//
+// Paging forward, the stream is read up to a byte limit sized for podLimit lines. The API may
+// cut the last line in half at the limit, so a stream that used all of it loses its last
+// entry, and is read again with a limit estimated from the lines it had until it has
+// podLimit entries or the limit cannot grow.
+func (f *Fetcher) fetchTarget(ctx context.Context, t sourcedTarget, lastReadTimes map[string]string, config types.Config, podLimit int, backward bool) targetRead {
+	cursorKey := pagination.CursorKey(t.Pod, t.Container, t.Restart)
+	sinceTime, window := readPlan(cursorKey, lastReadTimes, config)
+
+	if config.PodTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.PodTimeout)
+		defer cancel()
+	}
 
-	opts := &corev1.PodLogOptions{
-		Container:  types.DefaultContainerName,
-		Timestamps: true,
-		LimitBytes: &limitBytes,
+	// Paging backward needs the newest lines of the window, which a byte limit would cut.
+	if backward {
+		var entries []types.LogEntry
+		_, err := f.readStream(ctx, t, sinceTime, 0, func(logCh <-chan string) streamRead {
+			entries = f.processor.ProcessLatestLinesFromChannel(logCh, t.Target, window, config.Limit)
+			return streamRead{}
+		})
+		return finishedRead(ctx, entries, err)
 	}
 
-	// Add since time if provided
-	if sinceTime != "" {
-		if sinceTimeObj, err := time.Parse(time.RFC3339, sinceTime); err == nil {
-			metaTime := metav1.NewTime(sinceTimeObj)
-			opts.SinceTime = &metaTime
+	limitBytes := int64(podLimit) * initialLineBytes
+	for attempt := 1; ; attempt++ {
+		var entries []types.LogEntry
+		read, err := f.readStream(ctx, t, sinceTime, limitBytes, func(logCh <-chan string) streamRead {
+			return f.processor.processChannel(logCh, t.Target, window, func(entry types.LogEntry) {
+				entries = append(entries, entry)
+			})
+		})
+		if err != nil || ctx.Err() != nil || read.bytes < limitBytes || read.pastWindow {
+			return finishedRead(ctx, entries, err)
 		}
+
+		final := attempt == maxReadAttempts || limitBytes == maxLimitBytes
+		switch {
+		case read.last.IsZero():
+			// Every line read was at or before the cursor, so nothing is known past it.
+			if final {
+				return finishedRead(ctx, entries, nil)
+			}
+		case read.prevEnd.IsZero():
+			// A single entry larger than the limit is taken as it is, or the stream could
+			// never move past it.
+			if final {
+				return targetRead{entries: entries, horizon: read.lastEnd, progress: read.lastEnd}
+			}
+		default:
+			entries = slices.DeleteFunc(entries, func(entry types.LogEntry) bool {
+				return pagination.FirstLine(entry).Compare(read.last) >= 0
+			})
+			if len(entries) >= podLimit || final {
+				return targetRead{entries: entries, horizon: read.last, progress: read.prevEnd}
+			}
+		}
+
+		limitBytes = nextLimitBytes(limitBytes, read, len(entries), podLimit)
 	}
+}
 
-	req := f.clientset.CoreV1().Pods(namespace).GetLogs(pod.Name, opts)
-	podLogs, err := req.Stream(ctx)
+// finishedRead is the read of a stream that ended, by itself or because ctx did
+func finishedRead(ctx context.Context, entries []types.LogEntry, err error) targetRead {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return targetRead{timedOut: true}
+	}
 	if err != nil {
+		return targetRead{err: err}
+	}
+	return targetRead{entries: entries}
+}
+
+// nextLimitBytes estimates the byte limit that reads podLimit entries, from the size of the
+// lines of a read that fell short and how many of them it took per entry kept. The limit at
+// least doubles, so a filter that kept nothing still gets through the stream.
+func nextLimitBytes(limitBytes int64, read streamRead, kept, podLimit int) int64 {
+	lineBytes := float64(read.bytes) / float64(read.lines)
+	linesPerEntry := float64(read.lines) / float64(max(kept, 1))
+	estimate := int64(lineBytes * linesPerEntry * float64(podLimit) * 1.25)
+	return min(max(estimate, 2*limitBytes), maxLimitBytes)
+}
+
+// readStream reads a stream once, through process. It returns what process made of it and
+// the error the stream failed with, if it ended by itself.
+func (f *Fetcher) readStream(ctx context.Context, t sourcedTarget, sinceTime string, limitBytes int64, process func(<-chan string) streamRead) (streamRead, error) {
+	// Cancelling releases the producer when the processor stops at the end of the window.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The error is sent before logCh closes, so it is there once the processor has read all.
+	logCh := make(chan string, 100)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(logCh)
+		errCh <- t.source.Stream(ctx, t.Target, sinceTime, limitBytes, logCh)
+	}()
+
+	read := process(logCh)
+
+	// A processor that stopped at the end of the window leaves the stream running; only a
+	// stream that ended on its own has an error to report.
+	select {
+	case err := <-errCh:
+		return read, err
+	default:
+		return read, nil
+	}
+}
+
+// readPlan decides where a stream is read from and which of its lines belong to the page.
+// Forward, the stream resumes at its cursor. Backward, it is read from the start of the
+// window up to its cursor, since the API only reads forward.
+func readPlan(cursorKey string, lastReadTimes map[string]string, config types.Config) (string, Window) {
+	if pagination.TokenDirection(lastReadTimes, config.Direction) == types.DirectionBackward {
+		return config.StartTime, Window{Before: pagination.ParsePosition(getLastReadTime(cursorKey, lastReadTimes)), End: config.EndTime}
+	}
+	return determineSinceTime(cursorKey, lastReadTimes, config.StartTime), Window{After: pagination.ParsePosition(getLastReadTime(cursorKey, lastReadTimes)), End: config.EndTime}
+}
+
+// podTargets expands a pod into the streams read from it: the current instance of every
+// selected container and, depending on the previous mode, the instance before its restart.
+func podTargets(pod *corev1.Pod, config types.Config) []Target {
+	info := podInfo(pod.Name, string(pod.UID), pod.Namespace, config)
+
+	var targets []Target
+	for _, container := range podContainers(pod, config.Containers) {
+		status := containerStatus(pod, container)
+
+		var restarts int
+		if status != nil {
+			restarts = int(status.RestartCount)
+		}
+
+		var metadata *types.Metadata
+		if config.IncludeMetadata {
+			metadata = labelMetadata(pod.Labels, restarts)
+			metadata.Node = pod.Spec.NodeName
+			metadata.ImageTag = imageTag(containerImage(pod, container))
+		}
+
+		if restarts > 0 && readPrevious(status, config) {
+			targets = append(targets, Target{Namespace: pod.Namespace, Pod: info, Container: container, Restart: restarts - 1, Previous: true, Finished: true, Metadata: metadata})
+		}
+		finished := status != nil && status.State.Terminated != nil
+		targets = append(targets, Target{Namespace: pod.Namespace, Pod: info, Container: container, Restart: restarts, Finished: finished, Metadata: metadata})
+	}
+	return targets
+}
+
+// podInfo identifies a pod in the output, naming its namespace when the query spans several
+func podInfo(name, uid, namespace string, config types.Config) types.PodInfo {
+	info := types.PodInfo{Name: name, ID: uid}
+	if config.SpansNamespaces() {
+		info.Namespace = namespace
+	}
+	return info
+}
+
+// labelMetadata is the metadata a pod's labels and a container's restarts tell
+func labelMetadata(labels map[string]string, restarts int) *types.Metadata {
+	return &types.Metadata{
+		DeploymentID: labels["deployment_id"],
+		ScopeID:      labels["scope_id"],
+		JobName:      labels[types.JobNameLabel],
+		RestartCount: restarts,
+	}
+}
+
+// containerImage finds the image of a container or init container by name
+func containerImage(pod *corev1.Pod, name string) string {
+	for _, containers := range [][]corev1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, container := range containers {
+			if container.Name == name {
+				return container.Image
+			}
+		}
+	}
+	return ""
+}
+
+// imageTag is the tag of an image reference, or its digest when pinned to one. An image
+// without either runs latest.
+func imageTag(image string) string {
+	if image == "" {
 		return ""
 	}
-	defer podLogs.Close()
-
-	var logContent strings.Builder
-	scanner := bufio.NewScanner(podLogs)
-	for scanner.Scan() {
-		logContent.WriteString(scanner.Text())
-		logContent.WriteString("\n")
-	}
-
-	return logContent.String()
-}
-
-func (f *Fetcher) streamPodLogs(ctx context.Context, pod *corev1.Pod, namespace, sinceTime string, limitBytes int64, logCh chan<- string) {
-    opts := &corev1.PodLogOptions{
-        Container:  types.DefaultContainerName,
-        Timestamps: true,
-        LimitBytes: &limitBytes,
-    }
-    if sinceTime != "" {
-        if sinceTimeObj, err := time.Parse(time.RFC3339, sinceTime); err == nil {
-            metaTime := metav1.NewTime(sinceTimeObj)
-            opts.SinceTime = &metaTime
-        }
-    }
-    req := f.clientset.CoreV1().Pods(namespace).GetLogs(pod.Name, opts)
-    podLogs, err := req.Stream(ctx)
-    if err != nil {
-        return
-    }
-    defer podLogs.Close()
-
-    scanner := bufio.NewScanner(podLogs)
-    for scanner.Scan() {
-        select {
-        case logCh <- scanner.Text():
-        case <-ctx.Done():
-            return
-        }
-    }
-}
-
-// determineSinceTime determines the appropriate since time for a pod
-func determineSinceTime(podUID string, lastReadTimes map[string]string, startTime string) string {
-	if lastTime, exists := lastReadTimes[podUID]; exists && lastTime != "" && lastTime != "null" {
-		return lastTime
+	if _, digest, ok := strings.Cut(image, "@"); ok {
+		return digest
+	}
+	// A colon before the last slash separates a registry port, not a tag.
+	name := image[strings.LastIndex(image, "/")+1:]
+	if _, tag, ok := strings.Cut(name, ":"); ok {
+		return tag
+	}
+	return "latest"
+}
+
+// readPrevious reports whether the instance before a container's last restart is read. In
+// auto mode it is read only when it stopped inside the window, so it has lines to offer.
+func readPrevious(status *corev1.ContainerStatus, config types.Config) bool {
+	switch config.Previous {
+	case types.PreviousAlways:
+		return true
+	case types.PreviousAuto:
+		terminated := status.LastTerminationState.Terminated
+		if terminated == nil {
+			return false
+		}
+		startTime, err := time.Parse(time.RFC3339, config.StartTime)
+		return err != nil || !terminated.FinishedAt.Time.Before(startTime)
+	default:
+		return false
+	}
+}
+
+// containerStatus finds the status of a container or init container by name
+func containerStatus(pod *corev1.Pod, name string) *corev1.ContainerStatus {
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for i := range statuses {
+			if statuses[i].Name == name {
+				return &statuses[i]
+			}
+		}
+	}
+	return nil
+}
+
+// podContainers resolves the requested container names against the pod spec. "all" selects
+// the init and regular containers; names the pod does not run are skipped.
+func podContainers(pod *corev1.Pod, requested []string) []string {
+	available := make([]string, 0, len(pod.Spec.InitContainers)+len(pod.Spec.Containers))
+	for _, container := range pod.Spec.InitContainers {
+		available = append(available, container.Name)
+	}
+	for _, container := range pod.Spec.Containers {
+		available = append(available, container.Name)
+	}
+	return selectContainers(available, requested)
+}
+
+// selectContainers resolves the requested container names against the ones a pod has
+func selectContainers(available, requested []string) []string {
+	if len(requested) == 0 {
+		requested = []string{types.DefaultContainerName}
+	}
+	if slices.Contains(requested, types.AllContainers) {
+		return available
+	}
+
+	var selected []string
+	for _, name := range requested {
+		if slices.Contains(available, name) && !slices.Contains(selected, name) {
+			selected = append(selected, name)
+		}
+	}
+	return selected
+}
+
+// determineSinceTime determines the appropriate since time for a stream. The API reads from a
+// whole second, so every line sharing the cursor's timestamp is read again and numbered.
+func determineSinceTime(cursorKey string, lastReadTimes map[string]string, startTime string) string {
+	if lastTime, exists := lastReadTimes[cursorKey]; exists {
+		if position := pagination.ParsePosition(lastTime); !position.IsZero() {
+			return position.Time
+		}
 	}
 	return startTime
 }
 
-// getLastReadTime retrieves the last read time for a specific pod
-func getLastReadTime(podUID string, lastReadTimes map[string]string) string {
-	if lastTime, exists := lastReadTimes[podUID]; exists {
+// getLastReadTime retrieves the last read time for a specific stream
+func getLastReadTime(cursorKey string, lastReadTimes map[string]string) string {
+	if lastTime, exists := lastReadTimes[cursorKey]; exists {
 		return lastTime
 	}
 	return ""
-}
\ No newline at end of file
+}
@@ -0,0 +1,227 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"kube-logger-go/internal/types"
+)
+
+func podWith(initContainers []string, containers ...string) *corev1.Pod {
+	pod := &corev1.Pod{}
+	for _, name := range initContainers {
+		pod.Spec.InitContainers = append(pod.Spec.InitContainers, corev1.Container{Name: name})
+	}
+	for _, name := range containers {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: name})
+	}
+	return pod
+}
+
+func TestPodContainersDefaultsToTheApplicationContainer(t *testing.T) {
+	pod := podWith(nil, "application", "istio-proxy")
+
+	got := podContainers(pod, nil)
+
+	if !slices.Equal(got, []string{types.DefaultContainerName}) {
+		t.Errorf("expected only the application container, got %v", got)
+	}
+}
+
+// Sidecars and init containers are where the reason a pod misbehaves usually is.
+func TestPodContainersAllIncludesInitContainersAndSidecars(t *testing.T) {
+	pod := podWith([]string{"migrations"}, "application", "traffic-manager")
+
+	got := podContainers(pod, []string{types.AllContainers})
+
+	want := []string{"migrations", "application", "traffic-manager"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+// Asking the API for a container the pod does not run only fails the stream.
+func TestPodContainersSkipsContainersThePodDoesNotRun(t *testing.T) {
+	pod := podWith(nil, "application", "istio-proxy")
+
+	got := podContainers(pod, []string{"istio-proxy", "traffic-manager", "istio-proxy"})
+
+	if !slices.Equal(got, []string{"istio-proxy"}) {
+		t.Errorf("expected only istio-proxy once, got %v", got)
+	}
+}
+
+func crashedPod(restarts int32, finishedAt time.Time) *corev1.Pod {
+	pod := podWith(nil, "application")
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:         "application",
+		RestartCount: restarts,
+		LastTerminationState: corev1.ContainerState{
+			Terminated: &corev1.ContainerStateTerminated{FinishedAt: metav1.NewTime(finishedAt)},
+		},
+	}}
+	return pod
+}
+
+func TestPodTargetsReadsOnlyTheCurrentInstanceByDefault(t *testing.T) {
+	pod := crashedPod(3, time.Date(2026, 8, 17, 10, 0, 0, 0, time.UTC))
+
+	targets := podTargets(pod, types.Config{})
+
+	if len(targets) != 1 || targets[0].Previous || targets[0].Restart != 3 {
+		t.Errorf("expected only the current instance, generation 3, got %+v", targets)
+	}
+}
+
+// The previous instance goes first: it is older, and carries the generation before the restart.
+func TestPodTargetsAddsThePreviousInstanceOfARestartedContainer(t *testing.T) {
+	pod := crashedPod(3, time.Date(2026, 8, 17, 10, 0, 0, 0, time.UTC))
+
+	targets := podTargets(pod, types.Config{Previous: types.PreviousAlways})
+
+	if len(targets) != 2 {
+		t.Fatalf("expected the previous and current instances, got %+v", targets)
+	}
+	if !targets[0].Previous || targets[0].Restart != 2 {
+		t.Errorf("expected the previous instance with generation 2, got %+v", targets[0])
+	}
+	if targets[1].Previous || targets[1].Restart != 3 {
+		t.Errorf("expected the current instance with generation 3, got %+v", targets[1])
+	}
+}
+
+func TestPodTargetsNeverAsksForThePreviousInstanceOfAContainerThatNeverRestarted(t *testing.T) {
+	pod := podWith(nil, "application")
+
+	targets := podTargets(pod, types.Config{Previous: types.PreviousAlways})
+
+	if len(targets) != 1 || targets[0].Previous {
+		t.Errorf("expected only the current instance, got %+v", targets)
+	}
+}
+
+// A previous instance that stopped before the window cannot have lines inside it.
+func TestPodTargetsAutoReadsThePreviousInstanceOnlyWhenItEndedInsideTheWindow(t *testing.T) {
+	cfg := types.Config{Previous: types.PreviousAuto, StartTime: "2026-08-17T10:00:00Z"}
+
+	inside := podTargets(crashedPod(1, time.Date(2026, 8, 17, 10, 30, 0, 0, time.UTC)), cfg)
+	before := podTargets(crashedPod(1, time.Date(2026, 8, 17, 9, 30, 0, 0, time.UTC)), cfg)
+
+	if len(inside) != 2 {
+		t.Errorf("expected the previous instance that crashed inside the window, got %+v", inside)
+	}
+	if len(before) != 1 {
+		t.Errorf("expected no previous instance when it crashed before the window, got %+v", before)
+	}
+}
+
+// Metadata is opt-in, and the previous instance carries the container's, not its own.
+func TestPodTargetsAttachMetadataWhenAskedFor(t *testing.T) {
+	pod := crashedPod(2, time.Date(2026, 8, 17, 10, 0, 0, 0, time.UTC))
+	pod.Labels = map[string]string{"deployment_id": "7", "scope_id": "2"}
+	pod.Spec.NodeName = "node-1"
+	pod.Spec.Containers[0].Image = "registry.local:5000/app:v1.4.2"
+
+	if targets := podTargets(pod, types.Config{}); targets[0].Metadata != nil {
+		t.Errorf("expected no metadata unless asked for, got %+v", targets[0].Metadata)
+	}
+
+	targets := podTargets(pod, types.Config{IncludeMetadata: true, Previous: types.PreviousAlways})
+
+	want := types.Metadata{DeploymentID: "7", ScopeID: "2", Node: "node-1", ImageTag: "v1.4.2", RestartCount: 2}
+	for _, target := range targets {
+		if target.Metadata == nil || *target.Metadata != want {
+			t.Errorf("expected %+v, got %+v", want, target.Metadata)
+		}
+	}
+}
+
+func TestImageTag(t *testing.T) {
+	for image, want := range map[string]string{
+		"nginx":                          "latest",
+		"nginx:1.27":                     "1.27",
+		"registry.local:5000/app":        "latest",
+		"registry.local:5000/app:v2":     "v2",
+		"app@sha256:0123456789abcdef":    "sha256:0123456789abcdef",
+		"app:v2@sha256:0123456789abcdef": "sha256:0123456789abcdef",
+	} {
+		if got := imageTag(image); got != want {
+			t.Errorf("%s: expected %q, got %q", image, want, got)
+		}
+	}
+}
+
+// A pod found live and archived is read once, from the first source.
+func TestFetchConcurrentlyMergesSourcesPreferringTheFirst(t *testing.T) {
+	live := archiveWith(t, map[string]string{
+		"ns/a/pod.json":          scopePod,
+		"ns/a/application/0.log": "2026-08-17T10:00:01.000000000Z a live\n",
+	})
+	archived := archiveWith(t, map[string]string{
+		"ns/a/pod.json":          scopePod,
+		"ns/a/application/0.log": "2026-08-17T10:00:01.000000000Z a archived\n",
+		"ns/b/pod.json":          scopePod,
+		"ns/b/application/0.log": "2026-08-17T10:00:02.000000000Z b archived\n",
+	})
+
+	fetched, err := NewFetcher(NewProcessor(nil, nil, nil), live, archived).FetchConcurrently(context.Background(), map[string]string{}, types.Config{Namespaces: []string{"ns"}, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []string
+	for _, entry := range fetched.Entries {
+		messages = append(messages, entry.Message)
+	}
+	slices.Sort(messages)
+	if !slices.Equal(messages, []string{"a live", "b archived"}) {
+		t.Errorf("expected pod a from the first source and pod b from the second, got %q", messages)
+	}
+}
+
+// An archived log larger than the byte limit of a page is paged through, even when the
+// filter keeps nothing of the first reads.
+func TestFetchConcurrentlyPagesThroughAnArchiveLargerThanTheLimit(t *testing.T) {
+	var log strings.Builder
+	start := time.Date(2026, 8, 17, 10, 0, 0, 0, time.UTC)
+	for i := range 8000 {
+		message := "routine line"
+		if i == 7990 {
+			message = "needle"
+		}
+		fmt.Fprintf(&log, "%s %s\n", start.Add(time.Duration(i)*time.Millisecond).Format("2006-01-02T15:04:05.000000000Z07:00"), message)
+	}
+	source := archiveWith(t, map[string]string{
+		"ns/a/pod.json":          scopePod,
+		"ns/a/application/0.log": log.String(),
+	})
+	fetcher := NewFetcher(NewProcessor(mustCompileFilter(t, "needle"), nil, nil), source)
+	config := types.Config{Namespaces: []string{"ns"}, Limit: 1, Direction: types.DirectionForward}
+
+	cursors := map[string]string{}
+	for page := 1; page <= 20; page++ {
+		fetched, err := fetcher.FetchConcurrently(context.Background(), cursors, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fetched.Entries) > 0 {
+			if fetched.Entries[0].Message != "needle" {
+				t.Errorf("expected the matching line, got %q", fetched.Entries[0].Message)
+			}
+			return
+		}
+		if !fetched.Unfinished() {
+			t.Fatalf("page %d: expected the stream to be unfinished before reaching the matching line", page)
+		}
+		maps.Copy(cursors, fetched.Progress)
+	}
+	t.Fatal("the matching line was not reached in 20 pages")
+}
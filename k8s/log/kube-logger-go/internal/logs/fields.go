@@ -0,0 +1,181 @@
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"kube-logger-go/internal/types"
+)
+
+// Keys that carry the level, logger and trace id, in the order they are looked up. Loggers
+// disagree on the names, these are the ones of the common Java, Go, Python and Node loggers.
+var (
+	levelKeys   = []string{"level", "lvl", "severity", "levelname", "log.level", "@l"}
+	loggerKeys  = []string{"logger", "logger_name", "loggerName", "log.logger"}
+	traceIDKeys = []string{"trace_id", "traceId", "traceID", "trace.id", "dd.trace_id", "@tr"}
+)
+
+// levelAliases normalizes the spellings of a level to the ones the log view facets on
+var levelAliases = map[string]string{
+	"warning":     "warn",
+	"err":         "error",
+	"information": "info",
+	"crit":        "critical",
+	"dbg":         "debug",
+	"trc":         "trace",
+}
+
+// numericLevels are the levels pino and bunyan write as numbers
+var numericLevels = map[string]string{
+	"10": "trace",
+	"20": "debug",
+	"30": "info",
+	"40": "warn",
+	"50": "error",
+	"60": "fatal",
+}
+
+// structure fills the level, logger, trace id and fields of an entry whose message is a JSON
+// object or logfmt. Other messages are left as they are.
+func structure(entry *types.LogEntry) {
+	fields, ok := parseFields(entry.Message)
+	if !ok {
+		return
+	}
+
+	entry.Fields = fields
+	entry.Level = normalizeLevel(lookupField(fields, levelKeys))
+	entry.Logger = lookupField(fields, loggerKeys)
+	entry.TraceID = lookupField(fields, traceIDKeys)
+}
+
+// parseFields parses a message as a JSON object, flattening nested objects into dotted keys,
+// or as logfmt. ok is false when it is neither.
+func parseFields(message string) (map[string]any, bool) {
+	trimmed := strings.TrimSpace(message)
+
+	if strings.HasPrefix(trimmed, "{") {
+		decoder := json.NewDecoder(strings.NewReader(trimmed))
+		decoder.UseNumber()
+
+		var object map[string]any
+		if err := decoder.Decode(&object); err != nil || decoder.More() {
+			return nil, false
+		}
+
+		fields := make(map[string]any, len(object))
+		flatten("", object, fields)
+		return fields, true
+	}
+
+	return parseLogfmt(trimmed)
+}
+
+// flatten copies a decoded JSON object into fields, naming nested values by their dotted path
+func flatten(prefix string, object map[string]any, fields map[string]any) {
+	for key, value := range object {
+		if nested, ok := value.(map[string]any); ok {
+			flatten(prefix+key+".", nested, fields)
+			continue
+		}
+		fields[prefix+key] = value
+	}
+}
+
+// parseLogfmt parses key=value pairs, where values may be double-quoted. Every token has to
+// be a pair, so prose that happens to contain an = is not mistaken for logfmt.
+func parseLogfmt(message string) (map[string]any, bool) {
+	if !strings.Contains(message, "=") {
+		return nil, false
+	}
+
+	fields := make(map[string]any)
+	for i := 0; i < len(message); {
+		if message[i] == ' ' {
+			i++
+			continue
+		}
+
+		equals := strings.IndexByte(message[i:], '=')
+		space := strings.IndexByte(message[i:], ' ')
+		if equals <= 0 || (space >= 0 && space < equals) {
+			return nil, false
+		}
+		key := message[i : i+equals]
+		i += equals + 1
+
+		var value strings.Builder
+		if i < len(message) && message[i] == '"' {
+			closed := false
+			for i++; i < len(message); i++ {
+				if message[i] == '\\' && i+1 < len(message) {
+					i++
+				} else if message[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				value.WriteByte(message[i])
+			}
+			if !closed || (i < len(message) && message[i] != ' ') {
+				return nil, false
+			}
+		} else {
+			for ; i < len(message) && message[i] != ' '; i++ {
+				value.WriteByte(message[i])
+			}
+		}
+
+		fields[key] = value.String()
+	}
+
+	return fields, len(fields) > 0
+}
+
+// lookupField returns the first of keys present in fields, as text
+func lookupField(fields map[string]any, keys []string) string {
+	for _, key := range keys {
+		if value, ok := fields[key]; ok && value != nil {
+			return fmt.Sprint(value)
+		}
+	}
+	return ""
+}
+
+// normalizeLevel lowercases a level and maps its aliases and numeric forms
+func normalizeLevel(level string) string {
+	level = strings.ToLower(strings.TrimSpace(level))
+	if alias, ok := levelAliases[level]; ok {
+		return alias
+	}
+	if named, ok := numericLevels[level]; ok {
+		return named
+	}
+	return level
+}
+
+// entryField returns a field of an entry as text, for filtering. The level, logger and trace
+// id answer to their normalized names whatever key the message used.
+func entryField(entry types.LogEntry, key string) (string, bool) {
+	switch key {
+	case "level":
+		if entry.Level != "" {
+			return entry.Level, true
+		}
+	case "logger":
+		if entry.Logger != "" {
+			return entry.Logger, true
+		}
+	case "trace_id":
+		if entry.TraceID != "" {
+			return entry.TraceID, true
+		}
+	}
+
+	value, ok := entry.Fields[key]
+	if !ok || value == nil {
+		return "", false
+	}
+	return fmt.Sprint(value), true
+}
@@ -0,0 +1,59 @@
+package logs
+
+import (
+	"testing"
+
+	"kube-logger-go/internal/types"
+)
+
+func structured(message string) types.LogEntry {
+	entry := types.LogEntry{Message: message}
+	structure(&entry)
+	return entry
+}
+
+func TestStructureParsesJSONMessages(t *testing.T) {
+	entry := structured(`{"level":"WARNING","logger":"checkout","trace_id":"abc123","msg":"slow","http":{"status":503}}`)
+
+	if entry.Level != "warn" {
+		t.Errorf("expected the level normalized to warn, got %q", entry.Level)
+	}
+	if entry.Logger != "checkout" || entry.TraceID != "abc123" {
+		t.Errorf("expected logger and trace id extracted, got %q and %q", entry.Logger, entry.TraceID)
+	}
+	if status, _ := entryField(entry, "http.status"); status != "503" {
+		t.Errorf("expected nested fields flattened to dotted keys, got %v", entry.Fields)
+	}
+}
+
+// pino and bunyan write the level as a number.
+func TestStructureMapsNumericLevels(t *testing.T) {
+	if entry := structured(`{"level":50,"msg":"boom"}`); entry.Level != "error" {
+		t.Errorf("expected level 50 to be error, got %q", entry.Level)
+	}
+}
+
+func TestStructureParsesLogfmtMessages(t *testing.T) {
+	entry := structured(`time=2026-08-17T10:00:00Z level=error msg="payment failed: card declined" status=402`)
+
+	if entry.Level != "error" {
+		t.Errorf("expected level error, got %q", entry.Level)
+	}
+	if entry.Fields["msg"] != "payment failed: card declined" {
+		t.Errorf("expected the quoted value whole, got %q", entry.Fields["msg"])
+	}
+}
+
+// Prose that mentions key=value must not grow fields, or field filters would misfire.
+func TestStructureLeavesPlainMessagesAlone(t *testing.T) {
+	for _, message := range []string{
+		"Started server on port 8080",
+		"retrying with timeout=5s after failure",
+		`{"unterminated": `,
+		`{"a":1} trailing`,
+	} {
+		if entry := structured(message); entry.Fields != nil || entry.Level != "" {
+			t.Errorf("expected %q to stay unstructured, got level %q and fields %v", message, entry.Level, entry.Fields)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package logs
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"kube-logger-go/internal/types"
+)
+
+// Filter is a compiled filter query, matched against the message of each entry.
+//
+// The query is a list of terms that must all match:
+//
+//	ERROR timeout          both words
+//	"connection refused"   the quoted phrase
+//	-healthcheck           anything but the word
+//	ERROR OR WARN          either word; OR binds tighter than the implicit AND
+//	/5\d\d/ /user=\w+/i    a regular expression, i makes it case-insensitive
+//	level=error            a field of a JSON or logfmt message
+//	status>=500            compared as numbers when both sides are; also !=, >, < and <=
+//
+// Regular expressions cannot contain spaces; use \s instead. A message without the field
+// matches key=value as a plain word, as the query did before fields were parsed.
+type Filter struct {
+	groups     [][]filterTerm
+	ignoreCase bool
+}
+
+// fieldTermPattern recognizes key<op>value. The key may not end in -, so an arrow like a->b
+// stays a plain word.
+var fieldTermPattern = regexp.MustCompile(`^([A-Za-z_@](?:[\w.@-]*[\w.@])?)(!=|>=|<=|=|>|<)(.+)$`)
+
+// filterTerm is one term of a query, matched on its own
+type filterTerm struct {
+	negate  bool
+	literal string
+	regex   *regexp.Regexp
+	field   *fieldCondition
+}
+
+// fieldCondition compares a field of a structured message
+type fieldCondition struct {
+	key      string
+	operator string
+	value    string
+}
+
+// filterToken is a term of the query as written, before it is compiled
+type filterToken struct {
+	text   string
+	quoted bool
+	negate bool
+}
+
+// CompileFilter compiles a filter query. An empty query compiles to a nil filter, which
+// matches every entry.
+func CompileFilter(query string, ignoreCase bool) (*Filter, error) {
+	tokens, err := tokenizeFilter(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	filter := &Filter{ignoreCase: ignoreCase}
+	joinNext := false
+
+	for i, token := range tokens {
+		if !token.quoted && !token.negate && token.text == "OR" {
+			if i == 0 || i == len(tokens)-1 || joinNext {
+				return nil, fmt.Errorf("OR must stand between two terms")
+			}
+			joinNext = true
+			continue
+		}
+
+		term, err := compileTerm(token, ignoreCase)
+		if err != nil {
+			return nil, err
+		}
+
+		if joinNext {
+			last := len(filter.groups) - 1
+			filter.groups[last] = append(filter.groups[last], term)
+			joinNext = false
+		} else {
+			filter.groups = append(filter.groups, []filterTerm{term})
+		}
+	}
+
+	return filter, nil
+}
+
+// Match reports whether an entry satisfies the query
+func (f *Filter) Match(entry types.LogEntry) bool {
+	if f == nil {
+		return true
+	}
+
+	message := entry.Message
+	if f.ignoreCase {
+		message = strings.ToLower(message)
+	}
+
+	for _, group := range f.groups {
+		matched := false
+		for _, term := range group {
+			if term.match(entry, message) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// match reports whether the term holds for an entry; message is its case-folded message when
+// the query ignores case
+func (t filterTerm) match(entry types.LogEntry, message string) bool {
+	var found bool
+	switch {
+	case t.regex != nil:
+		found = t.regex.MatchString(message)
+	case t.field != nil:
+		if value, ok := entryField(entry, t.field.key); ok {
+			found = t.field.holds(value)
+		} else {
+			found = t.field.operator == "=" && strings.Contains(message, t.literal)
+		}
+	default:
+		found = strings.Contains(message, t.literal)
+	}
+	return found != t.negate
+}
+
+// holds compares a field value, as numbers when both sides are numbers and as text otherwise.
+// Text comparison ignores case, so level=ERROR finds the normalized level error.
+func (c *fieldCondition) holds(value string) bool {
+	order := strings.Compare(strings.ToLower(value), strings.ToLower(c.value))
+	if left, err := strconv.ParseFloat(value, 64); err == nil {
+		if right, err := strconv.ParseFloat(c.value, 64); err == nil {
+			order = cmp.Compare(left, right)
+		}
+	}
+
+	switch c.operator {
+	case "=":
+		return order == 0
+	case "!=":
+		return order != 0
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	case "<":
+		return order < 0
+	default:
+		return order <= 0
+	}
+}
+
+// compileTerm compiles a single token into a term
+func compileTerm(token filterToken, ignoreCase bool) (filterTerm, error) {
+	term := filterTerm{negate: token.negate}
+
+	if !token.quoted {
+		if pattern, caseless, ok := regexLiteral(token.text); ok {
+			if caseless || ignoreCase {
+				pattern = "(?i)" + pattern
+			}
+			regex, err := regexp.Compile(pattern)
+			if err != nil {
+				return term, fmt.Errorf("invalid regular expression %s: %v", token.text, err)
+			}
+			term.regex = regex
+			return term, nil
+		}
+	}
+
+	term.literal = token.text
+	if ignoreCase {
+		term.literal = strings.ToLower(term.literal)
+	}
+
+	if !token.quoted {
+		if parts := fieldTermPattern.FindStringSubmatch(token.text); parts != nil {
+			term.field = &fieldCondition{key: parts[1], operator: parts[2], value: parts[3]}
+		}
+	}
+	return term, nil
+}
+
+// regexLiteral recognizes /pattern/ and /pattern/i. A token like /api/users is a plain word,
+// so paths can still be searched for as they are.
+func regexLiteral(text string) (pattern string, caseless, ok bool) {
+	if strings.HasSuffix(text, "/i") {
+		caseless = true
+		text = strings.TrimSuffix(text, "i")
+	}
+	if len(text) < 3 || text[0] != '/' || text[len(text)-1] != '/' {
+		return "", false, false
+	}
+	return strings.ReplaceAll(text[1:len(text)-1], `\/`, "/"), caseless, true
+}
+
+// tokenizeFilter splits a query on whitespace, keeping quoted phrases whole. A leading - marks
+// an exclusion; a quoted phrase may contain \" and \\.
+func tokenizeFilter(query string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(query)
+
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		negate := false
+		if runes[i] == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			negate = true
+			i++
+		}
+
+		if runes[i] == '"' {
+			var phrase strings.Builder
+			closed := false
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+					i++
+				} else if runes[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				phrase.WriteRune(runes[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated quoted phrase")
+			}
+			if phrase.Len() == 0 {
+				return nil, fmt.Errorf("empty quoted phrase")
+			}
+			tokens = append(tokens, filterToken{text: phrase.String(), quoted: true, negate: negate})
+			continue
+		}
+
+		start := i
+		for i < len(runes) && !unicode.IsSpace(runes[i]) {
+			i++
+		}
+		tokens = append(tokens, filterToken{text: string(runes[start:i]), negate: negate})
+	}
+
+	return tokens, nil
+}
@@ -0,0 +1,124 @@
+package logs
+
+import (
+	"testing"
+
+	"kube-logger-go/internal/types"
+)
+
+func mustCompileFilter(t *testing.T, query string) *Filter {
+	t.Helper()
+
+	filter, err := CompileFilter(query, false)
+	if err != nil {
+		t.Fatalf("filter %q did not compile: %v", query, err)
+	}
+	return filter
+}
+
+func message(text string) types.LogEntry {
+	return types.LogEntry{Message: text}
+}
+
+func TestFilterMatches(t *testing.T) {
+	cases := []struct {
+		query   string
+		message string
+		want    bool
+	}{
+		{"", "anything", true},
+		{"ERROR timeout", "ERROR db timeout", true},
+		{"ERROR timeout", "ERROR db refused", false},
+		{`"connection refused"`, "dial: connection refused", true},
+		{`"connection refused"`, "refused connection", false},
+		{"ERROR -healthcheck", "ERROR in healthcheck", false},
+		{"ERROR -healthcheck", "ERROR in checkout", true},
+		{`-"GET /health"`, "GET /healthz 200", false},
+		{"ERROR OR WARN", "WARN slow query", true},
+		{"ERROR OR WARN", "INFO started", false},
+		{"ERROR OR WARN -retry", "WARN will retry", false},
+		{`/status=5\d\d/`, "status=503", true},
+		{`/status=5\d\d/`, "status=404", false},
+		{"/error/i", "ERROR upper", true},
+		{"/error/", "ERROR upper", false},
+		{"/api/users", "GET /api/users 200", true},
+		{`"say \"hi\""`, `they say "hi"`, true},
+		{`"-v"`, "run -v", true},
+	}
+
+	for _, c := range cases {
+		if got := mustCompileFilter(t, c.query).Match(message(c.message)); got != c.want {
+			t.Errorf("filter %q on %q: expected %v, got %v", c.query, c.message, c.want, got)
+		}
+	}
+}
+
+func TestFilterIgnoreCase(t *testing.T) {
+	filter, err := CompileFilter(`error "Connection Refused" /TIME\w+/`, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !filter.Match(message("ERROR: connection refused after timeout")) {
+		t.Error("expected every term to match regardless of case")
+	}
+}
+
+// An invalid query must be reported, not fall back to matching everything or nothing.
+func TestCompileFilterRejectsInvalidQueries(t *testing.T) {
+	for _, query := range []string{`"unterminated`, `""`, "OR ERROR", "ERROR OR", "ERROR OR OR WARN", "/(unclosed/"} {
+		if _, err := CompileFilter(query, false); err == nil {
+			t.Errorf("expected %q to be rejected", query)
+		}
+	}
+}
+
+// Terms used to match the raw line, timestamp included; only the message is visible to users.
+func TestProcessorMatchesTheFilterAgainstTheMessage(t *testing.T) {
+	entries := NewProcessor(mustCompileFilter(t, "/^started/"), nil, nil).ProcessLinesFromChannel(
+		linesChannel("2026-08-17T10:00:00.000000000Z started"), podA, Window{},
+	)
+
+	if len(entries) != 1 {
+		t.Errorf("expected the anchored regex to match the start of the message, got %d entries", len(entries))
+	}
+}
+
+func TestFilterMatchesFieldsOfStructuredMessages(t *testing.T) {
+	cases := []struct {
+		query   string
+		message string
+		want    bool
+	}{
+		{"level=error", `{"level":"ERROR","msg":"boom"}`, true},
+		{"level=error", `{"severity":"err","msg":"boom"}`, true},
+		{"level=error", `{"level":"info","msg":"error in the text"}`, false},
+		{"-level=debug", `level=debug msg=noise`, false},
+		{"status>=500", `{"status":503}`, true},
+		{"status>=500", `{"status":404}`, false},
+		{"status>=500", `{"status":"1000"}`, true},
+		{"status<500", `status=200 path=/`, true},
+		{"status!=200", `status=200 path=/`, false},
+		{"http.method=post", `{"http":{"method":"POST"}}`, true},
+		{"level=error OR status>=500", `{"level":"info","status":502}`, true},
+		{"status>=500", "plain text status 503", false},
+	}
+
+	for _, c := range cases {
+		if got := mustCompileFilter(t, c.query).Match(structured(c.message)); got != c.want {
+			t.Errorf("filter %q on %q: expected %v, got %v", c.query, c.message, c.want, got)
+		}
+	}
+}
+
+// Before fields were parsed key=value was a plain word; lines without the field keep that.
+func TestFilterFieldEqualityFallsBackToTheWordOnPlainMessages(t *testing.T) {
+	filter := mustCompileFilter(t, "user=alice")
+
+	if !filter.Match(structured("login ok for user=alice from 10.0.0.1")) {
+		t.Error("expected key=value to match as a word on an unstructured message")
+	}
+	if filter.Match(structured("login ok for user=bob")) {
+		t.Error("expected no match for another value")
+	}
+}
@@ -0,0 +1,197 @@
+package logs
+
+import (
+	"bufio"
+	"context"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"kube-logger-go/internal/kubernetes"
+	"kube-logger-go/internal/pagination"
+	"kube-logger-go/internal/types"
+)
+
+// followedStream is a container instance being followed
+type followedStream struct {
+	podUID string
+	cancel context.CancelFunc
+}
+
+// Follow sends the entries of every matching pod to out as they are written, until ctx is
+// cancelled. Streams are opened as the pod index reports new pods and container restarts,
+// and closed when their pod is deleted. Only pods the API still has are followed, so only
+// the API can be. A stream that fails to open or read is sent to warnings, once for each
+// reason, and opened again on the next report of its pod.
+func (s *KubernetesSource) Follow(ctx context.Context, processor *Processor, config types.Config, out chan<- types.LogEntry, warnings chan<- types.Warning) error {
+	// Without a start time only lines written from now on are followed.
+	sinceTime := config.StartTime
+	if sinceTime == "" {
+		sinceTime = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	active := make(map[string]followedStream)
+	lastReadTimes := make(map[string]string)
+	reported := make(map[string]string)
+
+	// A stream that keeps failing for the same reason, such as a denied permission, is only
+	// reported the first time
+	warn := func(cursorKey string, warning types.Warning) {
+		mu.Lock()
+		repeated := reported[cursorKey] == warning.Message
+		reported[cursorKey] = warning.Message
+		mu.Unlock()
+
+		if !repeated {
+			select {
+			case warnings <- warning:
+			case <-ctx.Done():
+			}
+		}
+	}
+
+	start := func(t Target) {
+		cursorKey := pagination.CursorKey(t.Pod, t.Container, t.Restart)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if _, exists := active[cursorKey]; exists {
+			return
+		}
+
+		streamCtx, cancel := context.WithCancel(ctx)
+		active[cursorKey] = followedStream{podUID: t.Pod.ID, cancel: cancel}
+		lastReadTime := lastReadTimes[cursorKey]
+		streamSince := determineSinceTime(cursorKey, lastReadTimes, sinceTime)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer cancel()
+
+			logCh := make(chan string, 100)
+			go func() {
+				defer close(logCh)
+				if err := s.followPodLogs(streamCtx, t, streamSince, logCh); err != nil && streamCtx.Err() == nil {
+					warn(cursorKey, streamWarning(t, err))
+				}
+			}()
+
+			lastRead := processor.FollowLinesFromChannel(streamCtx, logCh, t, Window{After: pagination.ParsePosition(lastReadTime), End: config.EndTime}, out)
+
+			// The next watch event for a container that is still running reopens its stream here.
+			mu.Lock()
+			delete(active, cursorKey)
+			if !lastRead.IsZero() {
+				lastReadTimes[cursorKey] = lastRead.String()
+			}
+			mu.Unlock()
+		}()
+	}
+
+	stopPod := func(podUID string) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, stream := range active {
+			if stream.podUID == podUID {
+				stream.cancel()
+			}
+		}
+	}
+
+	defer wg.Wait()
+
+	// The informers report the pods that already exist first, then every change to them
+	since, _ := time.Parse(time.RFC3339, sinceTime)
+	startPod := func(obj any) {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			for _, t := range followTargets(pod, config, since) {
+				start(t)
+			}
+		}
+	}
+	index := kubernetes.NewPodIndex(s.clientset, config)
+	err := index.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    startPod,
+		UpdateFunc: func(_, obj any) { startPod(obj) },
+		DeleteFunc: func(obj any) {
+			// A pod deleted while the watch was down is only known by its last state
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*corev1.Pod); ok {
+				stopPod(string(pod.UID))
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// The streams the handlers start are only waited for once the handlers can start no more
+	ctx, cancel := context.WithCancel(ctx)
+	defer index.Wait()
+	defer cancel()
+
+	if err := index.Start(ctx); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	return nil
+}
+
+// followTargets selects the container instances of a pod that can be followed: the current
+// instance of every selected container that is running, or that finished since the follow
+// started. A container that is still waiting is picked up by the watch event that reports it
+// running; a short-lived one, such as a scheduled task's, may already have finished by then.
+func followTargets(pod *corev1.Pod, config types.Config, since time.Time) []Target {
+	var targets []Target
+	for _, t := range podTargets(pod, config) {
+		if t.Previous {
+			continue
+		}
+		status := containerStatus(pod, t.Container)
+		if status == nil {
+			continue
+		}
+		if terminated := status.State.Terminated; status.State.Running != nil || terminated != nil && !terminated.FinishedAt.Time.Before(since) {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// followPodLogs streams a container's log as it is written, until the container stops or
+// ctx is cancelled. It returns why the stream could not be opened or read to its end.
+func (s *KubernetesSource) followPodLogs(ctx context.Context, target Target, sinceTime string, logCh chan<- string) error {
+	opts := &corev1.PodLogOptions{
+		Container:  target.Container,
+		Follow:     true,
+		Timestamps: true,
+	}
+	if sinceTimeObj, err := time.Parse(time.RFC3339, sinceTime); err == nil {
+		metaTime := metav1.NewTime(sinceTimeObj)
+		opts.SinceTime = &metaTime
+	}
+
+	podLogs, err := s.clientset.CoreV1().Pods(target.Namespace).GetLogs(target.Pod.Name, opts).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer podLogs.Close()
+
+	scanner := bufio.NewScanner(podLogs)
+	for scanner.Scan() {
+		select {
+		case logCh <- scanner.Text():
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return scanner.Err()
+}
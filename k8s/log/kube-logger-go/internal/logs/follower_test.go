@@ -0,0 +1,57 @@
+package logs
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"kube-logger-go/internal/types"
+)
+
+// Opening a stream on a waiting container fails; the watch reports it again once it runs.
+func TestFollowTargetsOnlyFollowsRunningContainers(t *testing.T) {
+	pod := podWith(nil, "application", "istio-proxy")
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "application", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+		{Name: "istio-proxy", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+	}
+
+	targets := followTargets(pod, types.Config{Containers: []string{types.AllContainers}}, time.Time{})
+
+	if len(targets) != 1 || targets[0].Container != "istio-proxy" {
+		t.Errorf("expected only the running istio-proxy, got %+v", targets)
+	}
+}
+
+func TestFollowTargetsLeavesOutPreviousInstances(t *testing.T) {
+	pod := podWith(nil, "application")
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:         "application",
+		RestartCount: 2,
+		State:        corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+	}}
+
+	targets := followTargets(pod, types.Config{Previous: types.PreviousAlways}, time.Time{})
+
+	if len(targets) != 1 || targets[0].Previous || targets[0].Restart != 2 {
+		t.Errorf("expected only the running instance, got %+v", targets)
+	}
+}
+
+// A scheduled task may run and finish between two watch events; its lines are still read.
+func TestFollowTargetsReadsContainersThatFinishedSinceTheFollowStarted(t *testing.T) {
+	since := time.Date(2026, 8, 17, 10, 0, 0, 0, time.UTC)
+	pod := podWith(nil, "application", "istio-proxy")
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "application", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{FinishedAt: metav1.NewTime(since.Add(time.Second))}}},
+		{Name: "istio-proxy", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{FinishedAt: metav1.NewTime(since.Add(-time.Second))}}},
+	}
+
+	targets := followTargets(pod, types.Config{Containers: []string{types.AllContainers}}, since)
+
+	if len(targets) != 1 || targets[0].Container != "application" {
+		t.Errorf("expected only the application that finished after the follow started, got %+v", targets)
+	}
+}
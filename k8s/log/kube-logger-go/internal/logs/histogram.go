@@ -0,0 +1,175 @@
+package logs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"kube-logger-go/internal/pagination"
+	"kube-logger-go/internal/types"
+)
+
+// ErrTooManyBuckets is returned for a histogram whose buckets, from the first to the last,
+// would be more than types.MaxHistogramBuckets
+var ErrTooManyBuckets = errors.New("too many histogram buckets")
+
+// counts holds the entries counted per bucket start and group. Without a grouping every
+// entry counts in the group "".
+type counts map[time.Time]map[string]int
+
+func (c counts) add(start time.Time, group string) {
+	if c[start] == nil {
+		c[start] = make(map[string]int)
+	}
+	c[start][group]++
+}
+
+func (c counts) merge(other counts) {
+	for start, groups := range other {
+		for group, n := range groups {
+			if c[start] == nil {
+				c[start] = make(map[string]int)
+			}
+			c[start][group] += n
+		}
+	}
+}
+
+// HistogramResult is what a histogram counted
+type HistogramResult struct {
+	Buckets []types.Bucket
+	Report
+}
+
+// Histogram counts the entries of the whole window that match the filter, per bucket of
+// config.Histogram. Buckets start at multiples of their length since the Unix epoch, so the
+// same query buckets the same way whatever its window, and every bucket from the start of
+// the window to its end is listed, empty or not. Streams are read without a limit, as for a
+// page, and a stream that times out or fails is left out of the counts.
+func (f *Fetcher) Histogram(ctx context.Context, config types.Config) (HistogramResult, error) {
+	targets, err := f.targets(ctx, config)
+	if err != nil {
+		return HistogramResult{}, err
+	}
+
+	result := HistogramResult{Report: Report{streams: len(targets)}}
+	total := make(counts)
+	var mu sync.Mutex
+
+	eachTarget(targets, config.Parallelism, func(t sourcedTarget) {
+		counted, timedOut, err := f.countTarget(ctx, t, config)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if !result.failed(t.Target, timedOut, err) {
+			total.merge(counted)
+		}
+	})
+
+	result.Buckets, err = buckets(total, config)
+	if err != nil {
+		return HistogramResult{}, err
+	}
+	result.sort()
+	return result, nil
+}
+
+// countTarget counts the entries of one stream
+func (f *Fetcher) countTarget(ctx context.Context, t sourcedTarget, config types.Config) (counts, bool, error) {
+	sinceTime, window := readPlan(pagination.CursorKey(t.Pod, t.Container, t.Restart), nil, config)
+
+	if config.PodTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.PodTimeout)
+		defer cancel()
+	}
+
+	counted := make(counts)
+	_, err := f.readStream(ctx, t, sinceTime, 0, func(logCh <-chan string) streamRead {
+		return f.processor.processChannel(logCh, t.Target, window, func(entry types.LogEntry) {
+			written, err := time.Parse(time.RFC3339Nano, entry.DateTime)
+			if err != nil {
+				return
+			}
+			counted.add(bucketStart(written, config.Histogram), histogramGroup(entry, config.HistogramBy))
+		})
+	})
+
+	read := finishedRead(ctx, nil, err)
+	return counted, read.timedOut, read.err
+}
+
+// histogramGroup is the group an entry is counted in. Pods are named with their namespace
+// when the query spans several.
+func histogramGroup(entry types.LogEntry, by string) string {
+	switch by {
+	case types.HistogramByPod:
+		if entry.Pod.Namespace != "" {
+			return entry.Pod.Namespace + "/" + entry.Pod.Name
+		}
+		return entry.Pod.Name
+	case types.HistogramByLevel:
+		if entry.Level == "" {
+			return types.UnknownLevel
+		}
+		return entry.Level
+	default:
+		return ""
+	}
+}
+
+// buckets lists the buckets from the start of the window, or the first entry counted, to its
+// end, or the last one. Without both ends of the window the entries decide how many there
+// are, so their number is only known, and capped, here.
+func buckets(total counts, config types.Config) ([]types.Bucket, error) {
+	starts := slices.SortedFunc(maps.Keys(total), func(a, b time.Time) int { return a.Compare(b) })
+
+	var first, last time.Time
+	if len(starts) > 0 {
+		first, last = starts[0], starts[len(starts)-1]
+	}
+	if start, err := time.Parse(time.RFC3339Nano, config.StartTime); err == nil {
+		first = bucketStart(start, config.Histogram)
+	}
+	if end, err := time.Parse(time.RFC3339Nano, config.EndTime); err == nil {
+		last = bucketStart(end, config.Histogram)
+	}
+
+	result := []types.Bucket{}
+	if first.IsZero() || last.IsZero() {
+		return result, nil
+	}
+	if n := last.Sub(first)/config.Histogram + 1; n > types.MaxHistogramBuckets {
+		return nil, fmt.Errorf("%w: %d, more than %d; use longer ones", ErrTooManyBuckets, n, types.MaxHistogramBuckets)
+	}
+
+	for start := first; !start.After(last); start = start.Add(config.Histogram) {
+		bucket := types.Bucket{Start: start.Format(time.RFC3339Nano)}
+		for group, n := range total[start] {
+			bucket.Count += n
+			if config.HistogramBy != "" {
+				if bucket.Groups == nil {
+					bucket.Groups = make(map[string]int)
+				}
+				bucket.Groups[group] = n
+			}
+		}
+		result = append(result, bucket)
+	}
+	return result, nil
+}
+
+// bucketStart is the start of the bucket t falls in, at a multiple of length since the Unix
+// epoch. time.Truncate counts from the zero time instead, which only agrees for lengths that
+// divide a day.
+func bucketStart(t time.Time, length time.Duration) time.Time {
+	offset := t.UnixNano() % int64(length)
+	if offset < 0 {
+		offset += int64(length)
+	}
+	return t.Add(-time.Duration(offset)).UTC()
+}
@@ -0,0 +1,65 @@
+package logs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"kube-logger-go/internal/types"
+)
+
+// Without a window the buckets span the entries counted, with the gaps between them listed.
+func TestBucketsSpanTheEntriesWithoutAWindow(t *testing.T) {
+	first := time.Date(2026, 8, 17, 10, 1, 0, 0, time.UTC)
+	total := make(counts)
+	total.add(first, types.UnknownLevel)
+	total.add(first.Add(2*time.Minute), "error")
+	total.add(first.Add(2*time.Minute), "error")
+
+	got, err := buckets(total, types.Config{Histogram: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("expected 3 buckets, got %+v", got)
+	}
+	if got[0].Count != 1 || got[1].Count != 0 || got[2].Count != 2 || got[2].Start != "2026-08-17T10:03:00Z" {
+		t.Errorf("unexpected buckets %+v", got)
+	}
+	if got[2].Groups != nil {
+		t.Errorf("expected no groups when not asked for, got %+v", got[2].Groups)
+	}
+}
+
+// Entries far apart without both ends of a window would list every bucket between them.
+func TestBucketsAreCappedWithoutAWindow(t *testing.T) {
+	first := time.Date(2026, 8, 17, 10, 0, 0, 0, time.UTC)
+	total := make(counts)
+	total.add(first, "")
+	total.add(first.Add(time.Hour), "")
+
+	if got, err := buckets(total, types.Config{Histogram: time.Millisecond}); !errors.Is(err, ErrTooManyBuckets) {
+		t.Errorf("expected too many buckets, got %d buckets and %v", len(got), err)
+	}
+	if got, err := buckets(total, types.Config{Histogram: time.Millisecond, StartTime: "2026-08-17T09:00:00Z"}); !errors.Is(err, ErrTooManyBuckets) {
+		t.Errorf("expected too many buckets up to the last entry, got %d buckets and %v", len(got), err)
+	}
+}
+
+// Buckets start at multiples of their length since the Unix epoch, which time.Truncate only
+// agrees with for lengths that divide a day.
+func TestBucketStartAlignsOnTheUnixEpoch(t *testing.T) {
+	at := time.Date(2026, 8, 17, 10, 4, 30, 0, time.UTC)
+
+	start := bucketStart(at, 7*time.Minute)
+	if start.Unix()%(7*60) != 0 || start.After(at) || at.Sub(start) >= 7*time.Minute {
+		t.Errorf("expected the 7 minute bucket of %s since the epoch, got %s", at, start)
+	}
+}
+
+func TestHistogramGroupCountsEntriesWithoutALevelAsUnknown(t *testing.T) {
+	if group := histogramGroup(types.LogEntry{Message: "plain text"}, types.HistogramByLevel); group != types.UnknownLevel {
+		t.Errorf("expected %q, got %q", types.UnknownLevel, group)
+	}
+}
@@ -0,0 +1,115 @@
+package logs
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"kube-logger-go/internal/types"
+)
+
+const (
+	// MaxMultilineLines caps how many lines are joined into one entry, so a stream that never
+	// writes a start line cannot hold everything it reads in a single entry.
+	MaxMultilineLines = 1000
+
+	// Continuation lines are written right after the line they continue, so a followed stream
+	// that stays quiet this long has finished the entry it was writing.
+	multilineFlushDelay = 500 * time.Millisecond
+)
+
+// continuationPatterns recognize the lines of Java and Python stack traces that follow the
+// line that logged the error
+var continuationPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^\s`),
+	regexp.MustCompile(`^Caused by: `),
+	regexp.MustCompile(`^Suppressed: `),
+	regexp.MustCompile(`^\.\.\. \d+ (more|common frames omitted)`),
+	regexp.MustCompile(`^Traceback \(most recent call last\):`),
+	regexp.MustCompile(`^(During handling of the above exception|The above exception was the direct cause)`),
+	regexp.MustCompile(`^([\w$]+\.)*[\w$]+(Exception|Error|Throwable)(: |$)`),
+}
+
+// Joiner decides which lines continue the entry before them instead of starting their own
+type Joiner struct {
+	start *regexp.Regexp
+}
+
+// NewJoiner creates a joiner. With a start pattern, every line that does not match it
+// continues the previous entry; without one, stack trace lines do. It returns nil, which
+// joins nothing, when multi-line assembly is off.
+func NewJoiner(enabled bool, startPattern string) (*Joiner, error) {
+	if startPattern == "" {
+		if !enabled {
+			return nil, nil
+		}
+		return &Joiner{}, nil
+	}
+
+	start, err := regexp.Compile(startPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid multi-line start pattern: %v", err)
+	}
+	return &Joiner{start: start}, nil
+}
+
+// continues reports whether a message belongs to the entry before it
+func (j *Joiner) continues(message string) bool {
+	if j.start != nil {
+		return !j.start.MatchString(message)
+	}
+
+	for _, pattern := range continuationPatterns {
+		if pattern.MatchString(message) {
+			return true
+		}
+	}
+	return false
+}
+
+// assembler joins the continuation lines of one stream into the entry they belong to. The
+// entry takes the position of its first line and remembers the one of its last, which is
+// where the stream resumes after it.
+type assembler struct {
+	joiner  *Joiner
+	pending *types.LogEntry
+	lines   int
+}
+
+// add takes the next line of the stream, as an entry of its own. It returns the entry that
+// the line completed, if any.
+func (a *assembler) add(entry types.LogEntry) (types.LogEntry, bool) {
+	if a.joiner == nil {
+		return entry, true
+	}
+
+	if a.pending != nil && a.lines < MaxMultilineLines && a.joiner.continues(entry.Message) {
+		a.pending.Message += "\n" + entry.Message
+		a.pending.LastDateTime = entry.DateTime
+		a.pending.LastSeq = entry.Seq
+		a.lines++
+		return types.LogEntry{}, false
+	}
+
+	completed, ok := a.flush()
+	a.pending = &entry
+	a.lines = 1
+	return completed, ok
+}
+
+// flush returns the entry still being assembled, if any
+func (a *assembler) flush() (types.LogEntry, bool) {
+	if a.pending == nil {
+		return types.LogEntry{}, false
+	}
+
+	completed := *a.pending
+	a.pending = nil
+	a.lines = 0
+	return completed, true
+}
+
+// assembling reports whether an entry is waiting for more lines
+func (a *assembler) assembling() bool {
+	return a.pending != nil
+}
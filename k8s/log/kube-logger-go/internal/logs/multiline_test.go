@@ -0,0 +1,87 @@
+package logs
+
+import "testing"
+
+func mustJoiner(t *testing.T, startPattern string) *Joiner {
+	t.Helper()
+
+	joiner, err := NewJoiner(true, startPattern)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return joiner
+}
+
+func joinLines(t *testing.T, joiner *Joiner, lines ...string) []string {
+	t.Helper()
+
+	entries := NewProcessor(nil, joiner, nil).ProcessLinesFromChannel(linesChannel(lines...), podA, Window{})
+
+	messages := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		messages = append(messages, entry.Message)
+	}
+	return messages
+}
+
+func TestJoinerJoinsJavaStackTraces(t *testing.T) {
+	messages := joinLines(t, mustJoiner(t, ""),
+		"2026-08-17T10:00:00.000000000Z ERROR request failed",
+		"2026-08-17T10:00:00.000000001Z java.lang.RuntimeException: wrapped",
+		"2026-08-17T10:00:00.000000002Z \tat com.example.Api.handle(Api.java:10)",
+		"2026-08-17T10:00:00.000000003Z Caused by: java.io.IOException: reset",
+		"2026-08-17T10:00:00.000000004Z \t... 12 more",
+		"2026-08-17T10:00:01.000000000Z INFO next request",
+	)
+
+	if len(messages) != 2 {
+		t.Fatalf("expected the trace joined into the error and the next line apart, got %q", messages)
+	}
+	if messages[1] != "INFO next request" {
+		t.Errorf("expected the next line on its own, got %q", messages[1])
+	}
+}
+
+func TestJoinerJoinsPythonTracebacks(t *testing.T) {
+	messages := joinLines(t, mustJoiner(t, ""),
+		"2026-08-17T10:00:00.000000000Z ERROR:root:task failed",
+		"2026-08-17T10:00:00.000000001Z Traceback (most recent call last):",
+		`2026-08-17T10:00:00.000000002Z   File "worker.py", line 3, in <module>`,
+		"2026-08-17T10:00:00.000000003Z ValueError: bad input",
+		"2026-08-17T10:00:01.000000000Z INFO:root:retrying",
+	)
+
+	if len(messages) != 2 {
+		t.Fatalf("expected the traceback joined into the error, got %q", messages)
+	}
+}
+
+func TestJoinerWithAStartPatternJoinsEverythingElse(t *testing.T) {
+	messages := joinLines(t, mustJoiner(t, `^\d{4}-\d\d-\d\d `),
+		"2026-08-17T10:00:00.000000000Z 2026-08-17 10:00:00 first",
+		"2026-08-17T10:00:00.000000001Z continued without indentation",
+		"2026-08-17T10:00:01.000000000Z 2026-08-17 10:00:01 second",
+	)
+
+	if len(messages) != 2 || messages[0] != "2026-08-17 10:00:00 first\ncontinued without indentation" {
+		t.Errorf("expected two entries, the first one joined, got %q", messages)
+	}
+}
+
+// Matching after joining is what lets a filter find the exception of a stack trace.
+func TestFilterMatchesAnywhereInAJoinedEntry(t *testing.T) {
+	entries := NewProcessor(mustCompileFilter(t, "IllegalStateException"), mustJoiner(t, ""), nil).ProcessLinesFromChannel(linesChannel(
+		"2026-08-17T10:00:00.000000000Z ERROR request failed",
+		"2026-08-17T10:00:00.000000001Z java.lang.IllegalStateException: boom",
+	), podA, Window{})
+
+	if len(entries) != 1 || entries[0].DateTime != "2026-08-17T10:00:00.000000000Z" {
+		t.Errorf("expected the whole entry, stamped with its first line, got %+v", entries)
+	}
+}
+
+func TestNewJoinerRejectsAnInvalidStartPattern(t *testing.T) {
+	if _, err := NewJoiner(false, "(unclosed"); err == nil {
+		t.Error("expected the invalid pattern to be rejected")
+	}
+}
@@ -2,152 +2,312 @@ package logs
 
 import (
 	"bufio"
+	"context"
+	"slices"
 	"strings"
 	"time"
 
+	"kube-logger-go/internal/pagination"
 	"kube-logger-go/internal/types"
 )
 
-// Processor handles log processing operations
-type Processor struct{}
+// Processor handles log processing operations. It only holds the compiled request, so one
+// processor is shared by every stream of the request.
+type Processor struct {
+	filter   *Filter
+	joiner   *Joiner
+	redactor *Redactor
+}
 
-// NewProcessor creates a new log processor instance
-func NewProcessor() *Processor {
-	return &Processor{}
+// NewProcessor creates a new log processor instance. A nil filter keeps every line, a nil
+// joiner keeps every line as an entry of its own and a nil redactor leaves messages as they
+// are.
+func NewProcessor(filter *Filter, joiner *Joiner, redactor *Redactor) *Processor {
+	return &Processor{
+		filter:   filter,
+		joiner:   joiner,
+		redactor: redactor,
+	}
 }
 
-// ProcessLinesFromChannel processes log lines received from a channel and returns structured log entries.
-// endTime is applied here because the Kubernetes API only accepts a lower bound (SinceTime).
-func (p *Processor) ProcessLinesFromChannel(logCh <-chan string, filterPattern, podName, podUID, lastReadTime, endTime string) []types.LogEntry {
-    var entries []types.LogEntry
-
-    var terms []string
-    if filterPattern != "" {
-        terms = strings.Fields(filterPattern)
-    }
-
-    for line := range logCh {
-        if line == "" {
-            continue
-        }
-
-        parts := strings.SplitN(line, " ", 2)
-        if len(parts) < 2 {
-            continue
-        }
-
-        timestamp := parts[0]
-        message := parts[1]
-
-        if !p.isValidTimestamp(timestamp) {
-            continue
-        }
-
-        if lastReadTime != "" && lastReadTime != "null" && lastReadTime != "empty" {
-            if timestamp <= lastReadTime {
-                continue
-            }
-        }
-
-        // The stream is chronological, so the first line past the window ends it.
-        if endTime != "" && timestamp > endTime {
-            break
-        }
-
-        if len(terms) > 0 {
-            matches := true
-            for _, term := range terms {
-                if !strings.Contains(line, term) {
-                    matches = false
-                    break
-                }
-            }
-            if !matches {
-                continue
-            }
-        }
-
-        entry := types.LogEntry{
-            Message:  message,
-            DateTime: timestamp,
-            Pod: types.PodInfo{
-                Name: podName,
-                ID:   podUID,
-            },
-        }
-        entries = append(entries, entry)
-    }
-
-    return entries
+// Window bounds the lines of a stream that are read. After and Before are exclusive cursors
+// left by a previous page, when paging forward and backward respectively; End is the
+// inclusive end of the query. The Kubernetes API only accepts a lower bound (SinceTime), so
+// the others are applied here.
+type Window struct {
+	After  pagination.Position
+	Before pagination.Position
+	End    string
 }
 
-// ProcessLines processes raw log content and returns structured log entries
-func (p *Processor) ProcessLines(logs, filterPattern, podName, podUID, lastReadTime string) []types.LogEntry {
-	if logs == "" {
-		return []types.LogEntry{}
+// sequencer numbers the lines of a stream that share a timestamp, as pagination.Position does
+type sequencer struct {
+	timestamp string
+	seq       int
+}
+
+// next returns the sequence of a line with the given timestamp
+func (s *sequencer) next(timestamp string) int {
+	if timestamp == s.timestamp {
+		s.seq++
+	} else {
+		s.timestamp = timestamp
+		s.seq = 0
 	}
+	return s.seq
+}
 
+// lineVerdict is what processing made of a single raw line
+type lineVerdict int
+
+const (
+	lineSkipped    lineVerdict = iota // no valid timestamp, or already read on a previous page
+	lineRead                          // inside the window
+	linePastWindow                    // at or after the end of the window, which ends a chronological stream
+)
+
+// ProcessLinesFromChannel processes log lines received from a channel and returns structured log entries.
+func (p *Processor) ProcessLinesFromChannel(logCh <-chan string, target Target, window Window) []types.LogEntry {
 	var entries []types.LogEntry
-	scanner := bufio.NewScanner(strings.NewReader(logs))
+	p.processChannel(logCh, target, window, func(entry types.LogEntry) {
+		entries = append(entries, entry)
+	})
+	return entries
+}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
-			continue
+// ProcessLatestLinesFromChannel is ProcessLinesFromChannel keeping only the newest limit entries,
+// for paging backward. The API streams oldest first, so the whole window has to be read.
+func (p *Processor) ProcessLatestLinesFromChannel(logCh <-chan string, target Target, window Window, limit int) []types.LogEntry {
+	if limit <= 0 {
+		return nil
+	}
+
+	// ring holds the newest entries so far; once full, oldest is the slot overwritten next.
+	ring := make([]types.LogEntry, 0, limit)
+	oldest := 0
+	p.processChannel(logCh, target, window, func(entry types.LogEntry) {
+		if len(ring) < limit {
+			ring = append(ring, entry)
+			return
 		}
+		ring[oldest] = entry
+		oldest = (oldest + 1) % limit
+	})
 
-		// Extract timestamp and message
-		parts := strings.SplitN(line, " ", 2)
-		if len(parts) < 2 {
-			continue
+	return slices.Concat(ring[oldest:], ring[:oldest])
+}
+
+// streamRead describes how much of a stream processChannel read. Entries count whether they
+// were kept or not.
+type streamRead struct {
+	bytes      int64               // bytes of the lines read, with their line breaks
+	lines      int                 // lines read
+	pastWindow bool                // a line past the window ended the stream
+	last       pagination.Position // first line of the last entry read
+	lastEnd    pagination.Position // last line of the last entry read
+	prevEnd    pagination.Position // last line of the entry read before it
+}
+
+// processChannel reads a stream until it ends or leaves the window, passing every entry that
+// matches to keep
+func (p *Processor) processChannel(logCh <-chan string, target Target, window Window, keep func(types.LogEntry)) streamRead {
+	lines := p.newAssembler()
+	var seq sequencer
+	var read streamRead
+
+	// complete hands over an entry that is complete, keeping it if it matches
+	complete := func(completed types.LogEntry) {
+		read.prevEnd = read.lastEnd
+		read.last, read.lastEnd = pagination.FirstLine(completed), pagination.LastLine(completed)
+		if p.keep(&completed) {
+			keep(completed)
 		}
+	}
+
+	for line := range logCh {
+		read.bytes += int64(len(line)) + 1
+		read.lines++
 
-		timestamp := parts[0]
-		message := parts[1]
+		entry, verdict := p.processLine(line, target, window, &seq)
 
-		// Validate timestamp format - skip lines with invalid timestamps
-		if !p.isValidTimestamp(timestamp) {
+		// The stream is chronological, so the first line past the window ends it.
+		if verdict == linePastWindow {
+			read.pastWindow = true
+			break
+		}
+		if verdict == lineSkipped {
 			continue
 		}
+		if completed, ok := lines.add(entry); ok {
+			complete(completed)
+		}
+	}
 
-		// Duplicate detection logic (matching bash script behavior)
-		if lastReadTime != "" && lastReadTime != "null" && lastReadTime != "empty" {
-			// If timestamp is same or older than last read time, skip it
-			if timestamp <= lastReadTime {
-				continue
+	if completed, ok := lines.flush(); ok {
+		complete(completed)
+	}
+	return read
+}
+
+// FollowLinesFromChannel sends the entries of a followed stream to out as they arrive, until
+// the stream ends, leaves the window or ctx is cancelled. It returns the position of the last
+// line of the last entry read, which resumes the stream without repeating lines if it has
+// to be reopened.
+func (p *Processor) FollowLinesFromChannel(ctx context.Context, logCh <-chan string, target Target, window Window, out chan<- types.LogEntry) pagination.Position {
+	lines := p.newAssembler()
+	lastRead := window.After
+	var seq sequencer
+
+	// send delivers a completed entry, reporting false once nobody is reading any more.
+	send := func(completed types.LogEntry) bool {
+		if p.keep(&completed) {
+			select {
+			case out <- completed:
+			case <-ctx.Done():
+				return false
 			}
 		}
+		lastRead = pagination.LastLine(completed)
+		return true
+	}
+
+	for {
+		// A followed stream may not write the line that completes an entry for a long while.
+		var quiet <-chan time.Time
+		if lines.assembling() {
+			quiet = time.After(multilineFlushDelay)
+		}
+
+		select {
+		case line, open := <-logCh:
+			if !open {
+				if completed, ok := lines.flush(); ok {
+					send(completed)
+				}
+				return lastRead
+			}
 
-		// Apply filter if specified (all terms in filterPattern must be present)
-		if filterPattern != "" {
-			terms := strings.Fields(filterPattern)
-			matches := true
-			for _, term := range terms {
-				if !strings.Contains(line, term) {
-					matches = false
-					break
+			entry, verdict := p.processLine(line, target, window, &seq)
+			if verdict == linePastWindow {
+				if completed, ok := lines.flush(); ok {
+					send(completed)
 				}
+				return lastRead
 			}
-			if !matches {
+			if verdict == lineSkipped {
 				continue
 			}
+			if completed, ok := lines.add(entry); ok && !send(completed) {
+				return lastRead
+			}
+		case <-quiet:
+			if completed, ok := lines.flush(); ok && !send(completed) {
+				return lastRead
+			}
+		case <-ctx.Done():
+			return lastRead
 		}
+	}
+}
+
+// ProcessLines processes raw log content and returns structured log entries
+func (p *Processor) ProcessLines(logs string, target Target, window Window) []types.LogEntry {
+	if logs == "" {
+		return []types.LogEntry{}
+	}
 
-		entry := types.LogEntry{
-			Message:  message,
-			DateTime: timestamp,
-			Pod: types.PodInfo{
-				Name: podName,
-				ID:   podUID,
-			},
+	var entries []types.LogEntry
+	lines := p.newAssembler()
+	var seq sequencer
+	scanner := bufio.NewScanner(strings.NewReader(logs))
+
+	for scanner.Scan() {
+		entry, verdict := p.processLine(scanner.Text(), target, window, &seq)
+		if verdict == linePastWindow {
+			break
+		}
+		if verdict == lineSkipped {
+			continue
+		}
+		if completed, ok := lines.add(entry); ok && p.keep(&completed) {
+			entries = append(entries, completed)
 		}
+	}
 
-		entries = append(entries, entry)
+	if completed, ok := lines.flush(); ok && p.keep(&completed) {
+		entries = append(entries, completed)
 	}
 
 	return entries
 }
 
+// processLine turns a raw "timestamp message" line into an entry of its own and decides what
+// to do with it. The entry is only meaningful for lines that are read.
+func (p *Processor) processLine(line string, target Target, window Window, seq *sequencer) (types.LogEntry, lineVerdict) {
+	// Extract timestamp and message
+	parts := strings.SplitN(line, " ", 2)
+	if len(parts) < 2 {
+		return types.LogEntry{}, lineSkipped
+	}
+
+	timestamp := parts[0]
+	message := parts[1]
+
+	// Validate timestamp format - skip lines with invalid timestamps
+	if !p.isValidTimestamp(timestamp) {
+		return types.LogEntry{}, lineSkipped
+	}
+
+	// Every line is numbered, read or not, so the numbers match the ones of earlier pages.
+	position := pagination.Position{Time: timestamp, Seq: seq.next(timestamp)}
+
+	// Duplicate detection logic (matching bash script behavior)
+	if !window.After.IsZero() && position.Compare(window.After) <= 0 {
+		return types.LogEntry{}, lineSkipped
+	}
+
+	if window.End != "" && timestamp > window.End {
+		return types.LogEntry{}, linePastWindow
+	}
+	if !window.Before.IsZero() && position.Compare(window.Before) >= 0 {
+		return types.LogEntry{}, linePastWindow
+	}
+
+	return newEntry(target, position, message), lineRead
+}
+
+// keep redacts a complete entry, parses its fields and reports whether it matches the filter.
+// It runs once lines are joined, so a filter matches anywhere in a stack trace, and redaction
+// comes first, so neither the filter nor the fields can reveal what it replaced.
+func (p *Processor) keep(entry *types.LogEntry) bool {
+	entry.Message, entry.Redactions = p.redactor.Redact(entry.Message)
+	structure(entry)
+	return p.filter.Match(*entry)
+}
+
+// newAssembler creates the assembler for one stream of the request
+func (p *Processor) newAssembler() *assembler {
+	return &assembler{joiner: p.joiner}
+}
+
+// newEntry builds the log entry for a line read from target. The lines of an events target
+// are entries from events.
+func newEntry(target Target, position pagination.Position, message string) types.LogEntry {
+	entry := types.LogEntry{
+		Message:   message,
+		DateTime:  position.Time,
+		Seq:       position.Seq,
+		Pod:       target.Pod,
+		Container: target.Container,
+		Restart:   target.Restart,
+		Metadata:  target.Metadata,
+	}
+	if target.Container == types.EventsContainer {
+		entry.Source = types.SourceEvent
+	}
+	return entry
+}
+
 // isValidTimestamp checks if a timestamp string is in a valid format
 func (p *Processor) isValidTimestamp(timestamp string) bool {
 	return ValidTimestamp(timestamp)
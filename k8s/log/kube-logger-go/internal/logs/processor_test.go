@@ -1,6 +1,11 @@
 package logs
 
-import "testing"
+import (
+	"context"
+	"testing"
+
+	"kube-logger-go/internal/types"
+)
 
 // A bound that is not RFC3339 compares below every timestamp, leaving the window unbounded.
 func TestValidTimestamp(t *testing.T) {
@@ -23,6 +28,8 @@ func TestValidTimestamp(t *testing.T) {
 	}
 }
 
+var podA = Target{Pod: types.PodInfo{Name: "pod-a", ID: "uid-a"}, Container: types.DefaultContainerName}
+
 func linesChannel(lines ...string) <-chan string {
 	ch := make(chan string, len(lines))
 	for _, line := range lines {
@@ -39,8 +46,8 @@ func TestProcessLinesFromChannelAppliesEndTime(t *testing.T) {
 		"2026-08-18T09:00:00.000000000Z after the window",
 	}
 
-	entries := NewProcessor().ProcessLinesFromChannel(
-		linesChannel(lines...), "", "pod-a", "uid-a", "", "2026-08-17T23:59:59Z",
+	entries := NewProcessor(nil, nil, nil).ProcessLinesFromChannel(
+		linesChannel(lines...), podA, Window{End: "2026-08-17T23:59:59Z"},
 	)
 
 	if len(entries) != 1 {
@@ -59,7 +66,7 @@ func TestProcessLinesFromChannelStopsReadingPastEndTime(t *testing.T) {
 	ch <- "2026-08-18T10:00:00.000000000Z should never be read"
 	close(ch)
 
-	entries := NewProcessor().ProcessLinesFromChannel(ch, "", "pod-a", "uid-a", "", "2026-08-17T23:59:59Z")
+	entries := NewProcessor(nil, nil, nil).ProcessLinesFromChannel(ch, podA, Window{End: "2026-08-17T23:59:59Z"})
 
 	if len(entries) != 1 {
 		t.Fatalf("expected 1 entry within the window, got %d", len(entries))
@@ -77,7 +84,7 @@ func TestProcessLinesFromChannelKeepsReadingThroughFilteredLines(t *testing.T) {
 	ch <- "2026-08-17T12:00:00.000000000Z keep me too"
 	close(ch)
 
-	entries := NewProcessor().ProcessLinesFromChannel(ch, "keep", "pod-a", "uid-a", "", "2026-08-17T23:59:59Z")
+	entries := NewProcessor(mustCompileFilter(t, "keep"), nil, nil).ProcessLinesFromChannel(ch, podA, Window{End: "2026-08-17T23:59:59Z"})
 
 	if len(entries) != 2 {
 		t.Fatalf("expected both matching entries, got %d", len(entries))
@@ -90,11 +97,43 @@ func TestProcessLinesFromChannelWithoutEndTimeKeepsEverything(t *testing.T) {
 		"2026-08-18T09:00:00.000000000Z second",
 	}
 
-	entries := NewProcessor().ProcessLinesFromChannel(
-		linesChannel(lines...), "", "pod-a", "uid-a", "", "",
+	entries := NewProcessor(nil, nil, nil).ProcessLinesFromChannel(
+		linesChannel(lines...), podA, Window{},
 	)
 
 	if len(entries) != 2 {
 		t.Fatalf("expected both entries when no upper bound is set, got %d", len(entries))
 	}
 }
+
+// Reopening a broken stream resumes from the last line read, filtered or not.
+func TestFollowLinesFromChannelReportsTheLastLineRead(t *testing.T) {
+	lines := []string{
+		"2026-08-17T10:00:00.000000000Z keep me",
+		"2026-08-17T11:00:00.000000000Z drop me",
+	}
+	out := make(chan types.LogEntry, len(lines))
+
+	lastRead := NewProcessor(mustCompileFilter(t, "keep"), nil, nil).FollowLinesFromChannel(context.Background(), linesChannel(lines...), podA, Window{}, out)
+
+	if len(out) != 1 {
+		t.Fatalf("expected 1 matching entry sent, got %d", len(out))
+	}
+	if lastRead.String() != "2026-08-17T11:00:00.000000000Z" {
+		t.Errorf("expected to resume after the filtered line, got %q", lastRead)
+	}
+}
+
+// Nobody reads out once the follower is cancelled, so sending must not block forever.
+func TestFollowLinesFromChannelStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	lastRead := NewProcessor(nil, nil, nil).FollowLinesFromChannel(
+		ctx, linesChannel("2026-08-17T10:00:00.000000000Z never sent"), podA, Window{}, make(chan types.LogEntry),
+	)
+
+	if !lastRead.IsZero() {
+		t.Errorf("expected nothing to count as read, got %q", lastRead.String())
+	}
+}
@@ -0,0 +1,137 @@
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// redactionRule replaces what pattern matches with [REDACTED:name], unless valid rejects the
+// match. A pattern with a group named keep leaves that group in place, so
+// "Bearer " stays in front of the token it introduces.
+type redactionRule struct {
+	name    string
+	pattern *regexp.Regexp
+	valid   func(match string) bool
+}
+
+// builtinRules detect the secrets and personal data applications most often log. Bearer
+// tokens go first, so a JWT sent as one counts once.
+var builtinRules = []redactionRule{
+	{name: "bearer", pattern: regexp.MustCompile(`(?i)(?P<keep>\bbearer\s+)[A-Za-z0-9\-._~+/]+=*`)},
+	{name: "jwt", pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)},
+	{name: "aws-key", pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{name: "email", pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+	{name: "pan", pattern: regexp.MustCompile(`\b[3-6]\d(?:[ -]?\d){11,17}\b`), valid: luhn},
+}
+
+// Redactor replaces secrets and personal data in messages before anything else sees them, so
+// neither the filter nor the parsed fields can reveal them
+type Redactor struct {
+	rules []redactionRule
+}
+
+// RedactionRule is a rule of a rules file: what Pattern matches is replaced with
+// [REDACTED:Name]
+type RedactionRule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// NewRedactor creates a redactor with the built-in rules and, if rulesFile is set, the rules
+// it lists as a JSON array of RedactionRule. It returns nil, which redacts nothing, when
+// redaction is off.
+func NewRedactor(enabled bool, rulesFile string) (*Redactor, error) {
+	if !enabled && rulesFile == "" {
+		return nil, nil
+	}
+
+	redactor := &Redactor{rules: slices.Clone(builtinRules)}
+	if rulesFile == "" {
+		return redactor, nil
+	}
+
+	data, err := os.ReadFile(rulesFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redaction rules: %v", err)
+	}
+	var rules []RedactionRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("redaction rules %s are not a JSON array of rules: %v", rulesFile, err)
+	}
+
+	for _, rule := range rules {
+		if rule.Name == "" || rule.Pattern == "" {
+			return nil, fmt.Errorf("redaction rule %q needs a name and a pattern", rule.Name)
+		}
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redaction rule %q: %v", rule.Name, err)
+		}
+		redactor.rules = append(redactor.rules, redactionRule{name: rule.Name, pattern: pattern})
+	}
+	return redactor, nil
+}
+
+// Redact returns message with every match replaced, and how many were
+func (r *Redactor) Redact(message string) (string, int) {
+	if r == nil {
+		return message, 0
+	}
+
+	redactions := 0
+	for _, rule := range r.rules {
+		message = rule.redact(message, &redactions)
+	}
+	return message, redactions
+}
+
+// redact replaces the matches of the rule in message, counting them in redactions. The keep
+// group is taken from where the match was found, as a pattern anchored on its context, such
+// as \b or ^, may not match the match alone.
+func (rule redactionRule) redact(message string, redactions *int) string {
+	keep := rule.pattern.SubexpIndex("keep")
+
+	var redacted strings.Builder
+	end, replaced := 0, false
+	for _, match := range rule.pattern.FindAllStringSubmatchIndex(message, -1) {
+		if rule.valid != nil && !rule.valid(message[match[0]:match[1]]) {
+			continue
+		}
+		*redactions++
+
+		redacted.WriteString(message[end:match[0]])
+		if keep >= 0 && match[2*keep] >= 0 {
+			redacted.WriteString(message[match[2*keep]:match[2*keep+1]])
+		}
+		redacted.WriteString("[REDACTED:" + rule.name + "]")
+		end, replaced = match[1], true
+	}
+	if !replaced {
+		return message
+	}
+	redacted.WriteString(message[end:])
+	return redacted.String()
+}
+
+// luhn reports whether the digits of a candidate card number pass the Luhn check, which
+// tells them apart from most other long numbers
+func luhn(candidate string) bool {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(candidate)
+
+	sum := 0
+	for i := range len(digits) {
+		digit := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
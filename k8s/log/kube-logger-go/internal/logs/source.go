@@ -0,0 +1,116 @@
+package logs
+
+import (
+	"bufio"
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	k8s "kube-logger-go/internal/kubernetes"
+	"kube-logger-go/internal/types"
+)
+
+// LogSource is where the logs of pods are read from
+type LogSource interface {
+	// Targets lists the container instances a query reads
+	Targets(ctx context.Context, config types.Config) ([]Target, error)
+	// Stream sends the lines of a target, with their timestamps, from sinceTime on. It reads
+	// at most limitBytes when it is positive, and stops early when ctx is cancelled.
+	Stream(ctx context.Context, target Target, sinceTime string, limitBytes int64, logCh chan<- string) error
+}
+
+// KubernetesSource reads the logs of running pods, and of the instances before their last
+// restart, from the Kubernetes API
+type KubernetesSource struct {
+	clientset kubernetes.Interface
+	pods      *k8s.PodIndex
+}
+
+// NewKubernetesSource creates a source reading through clientset, which lists the pods of
+// every query
+func NewKubernetesSource(clientset kubernetes.Interface) *KubernetesSource {
+	return &KubernetesSource{clientset: clientset}
+}
+
+// NewIndexedSource creates a source reading through clientset, which looks the pods of a
+// query up in pods when it holds them, and lists them otherwise
+func NewIndexedSource(clientset kubernetes.Interface, pods *k8s.PodIndex) *KubernetesSource {
+	return &KubernetesSource{clientset: clientset, pods: pods}
+}
+
+// Targets lists the selected containers of the matching pods, or of the requested instance.
+// An instance that no longer exists has no targets here, so another source may have it.
+func (s *KubernetesSource) Targets(ctx context.Context, config types.Config) ([]Target, error) {
+	var pods []corev1.Pod
+	var indexed bool
+	if config.InstanceID != "" {
+		pods, indexed = s.pods.PodsNamed(config, config.InstanceID)
+		if !indexed {
+			var err error
+			pods, err = k8s.GetPodsNamed(ctx, s.clientset, config, config.InstanceID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		pods, indexed = s.pods.Pods(config)
+		if !indexed {
+			var err error
+			pods, err = k8s.GetPods(ctx, s.clientset, config)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var targets []Target
+	for i := range pods {
+		targets = append(targets, podTargets(&pods[i], config)...)
+		if config.IncludeEvents {
+			targets = append(targets, eventsTarget(&pods[i], config))
+		}
+	}
+	return targets, nil
+}
+
+// Stream sends the lines of a container instance through the logs API, or the events of a
+// pod for its events target
+func (s *KubernetesSource) Stream(ctx context.Context, target Target, sinceTime string, limitBytes int64, logCh chan<- string) error {
+	if target.Container == types.EventsContainer {
+		return s.streamEvents(ctx, target, sinceTime, limitBytes, logCh)
+	}
+
+	opts := &corev1.PodLogOptions{
+		Container:  target.Container,
+		Previous:   target.Previous,
+		Timestamps: true,
+	}
+	if limitBytes > 0 {
+		opts.LimitBytes = &limitBytes
+	}
+	if sinceTime != "" {
+		if sinceTimeObj, err := time.Parse(time.RFC3339, sinceTime); err == nil {
+			metaTime := metav1.NewTime(sinceTimeObj)
+			opts.SinceTime = &metaTime
+		}
+	}
+	req := s.clientset.CoreV1().Pods(target.Namespace).GetLogs(target.Pod.Name, opts)
+	podLogs, err := req.Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer podLogs.Close()
+
+	scanner := bufio.NewScanner(podLogs)
+	for scanner.Scan() {
+		select {
+		case logCh <- scanner.Text():
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return scanner.Err()
+}
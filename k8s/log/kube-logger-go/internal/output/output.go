@@ -0,0 +1,208 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"strconv"
+
+	"kube-logger-go/internal/types"
+)
+
+// Output formats. JSON writes the response as one object, as it always was; the others write
+// one entry per line and leave the rest of the response to stderr.
+const (
+	JSON   = "json"
+	NDJSON = "ndjson"
+	Text   = "text"
+	CSV    = "csv"
+)
+
+// Color modes of the text format. Auto colors when writing to a terminal.
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// ANSI escapes of the text format
+const (
+	reset  = "\033[0m"
+	dim    = "\033[2m"
+	red    = "\033[31m"
+	yellow = "\033[33m"
+)
+
+// podColors are the colors pods are told apart by, picked by a hash of their name
+var podColors = []string{"\033[32m", "\033[34m", "\033[35m", "\033[36m", "\033[92m", "\033[94m", "\033[95m", "\033[96m"}
+
+// csvHeader names the columns of the CSV format
+var csvHeader = []string{"datetime", "namespace", "pod", "pod_id", "container", "restart", "level", "message"}
+
+// Valid reports whether format is one of the output formats
+func Valid(format string) bool {
+	switch format {
+	case JSON, NDJSON, Text, CSV:
+		return true
+	default:
+		return false
+	}
+}
+
+// UseColor resolves a color mode for w. Auto colors a terminal, unless NO_COLOR is set.
+func UseColor(mode string, w io.Writer) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorAuto:
+		if os.Getenv("NO_COLOR") != "" {
+			return false
+		}
+		file, ok := w.(*os.File)
+		if !ok {
+			return false
+		}
+		info, err := file.Stat()
+		return err == nil && info.Mode()&os.ModeCharDevice != 0
+	default:
+		return false
+	}
+}
+
+// EntryWriter writes entries one at a time, so a followed stream shows each as it arrives
+type EntryWriter interface {
+	Write(entry types.LogEntry) error
+}
+
+// NewEntryWriter creates the writer of format. JSON writes one object per entry, as ndjson.
+func NewEntryWriter(w io.Writer, format string, color bool) EntryWriter {
+	switch format {
+	case Text:
+		return &textWriter{w: w, color: color}
+	case CSV:
+		return &csvWriter{w: csv.NewWriter(w)}
+	default:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}
+	}
+}
+
+// WritePage writes a page in format. Formats that only hold entries write the token, the
+// pods that timed out, the warnings and the redaction count to stderr, so stdout can be piped as it is.
+func WritePage(stdout, stderr io.Writer, response types.Response, format string, color bool) error {
+	if format == JSON {
+		output, err := json.Marshal(response)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(stdout, string(output))
+		return err
+	}
+
+	writer := NewEntryWriter(stdout, format, color)
+	for _, entry := range response.Results {
+		if err := writer.Write(entry); err != nil {
+			return err
+		}
+	}
+
+	for _, pod := range response.TimedOut {
+		fmt.Fprintf(stderr, "Warning: pod %s timed out; the next page reads it again\n", podName(pod))
+	}
+	for _, warning := range response.Warnings {
+		WriteWarning(stderr, warning)
+	}
+	if response.Redactions > 0 {
+		fmt.Fprintf(stderr, "Redacted: %d\n", response.Redactions)
+	}
+	if response.NextPageToken != "" {
+		fmt.Fprintf(stderr, "Next page token: %s\n", response.NextPageToken)
+	}
+	return nil
+}
+
+// WriteWarning writes a container log that could not be read to stderr
+func WriteWarning(stderr io.Writer, warning types.Warning) {
+	fmt.Fprintf(stderr, "Warning: %s/%s restart %d could not be read: %s: %s\n", podName(warning.Pod), warning.Container, warning.Restart, warning.Reason, warning.Message)
+}
+
+// ndjsonWriter writes an entry as a JSON object per line
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(entry types.LogEntry) error {
+	return n.encoder.Encode(entry)
+}
+
+// textWriter writes an entry as "timestamp pod message", naming the container when it is not
+// the default one. Colors tell pods apart and mark errors and warnings.
+type textWriter struct {
+	w     io.Writer
+	color bool
+}
+
+func (t *textWriter) Write(entry types.LogEntry) error {
+	source := podName(entry.Pod)
+	if entry.Container != "" && entry.Container != types.DefaultContainerName {
+		source += "/" + entry.Container
+	}
+
+	if !t.color {
+		_, err := fmt.Fprintf(t.w, "%s %s %s\n", entry.DateTime, source, entry.Message)
+		return err
+	}
+
+	message := entry.Message
+	switch entry.Level {
+	case "error", "fatal", "critical":
+		message = red + message + reset
+	case "warn":
+		message = yellow + message + reset
+	}
+	_, err := fmt.Fprintf(t.w, "%s%s%s %s%s%s %s\n", dim, entry.DateTime, reset, podColor(entry.Pod.Name), source, reset, message)
+	return err
+}
+
+// csvWriter writes an entry as a CSV row, after a header row. Rows are flushed as they are
+// written, so a followed stream is not held back.
+type csvWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func (c *csvWriter) Write(entry types.LogEntry) error {
+	if !c.wroteHeader {
+		if err := c.w.Write(csvHeader); err != nil {
+			return err
+		}
+		c.wroteHeader = true
+	}
+
+	row := []string{
+		entry.DateTime, entry.Pod.Namespace, entry.Pod.Name, entry.Pod.ID,
+		entry.Container, strconv.Itoa(entry.Restart), entry.Level, entry.Message,
+	}
+	if err := c.w.Write(row); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// podName names a pod with its namespace when the query spans several
+func podName(pod types.PodInfo) string {
+	if pod.Namespace != "" {
+		return pod.Namespace + "/" + pod.Name
+	}
+	return pod.Name
+}
+
+// podColor picks the color of a pod, the same on every page
+func podColor(name string) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(name))
+	return podColors[hash.Sum32()%uint32(len(podColors))]
+}
@@ -0,0 +1,79 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"kube-logger-go/internal/types"
+)
+
+var page = types.Response{
+	Results: []types.LogEntry{
+		{DateTime: "2026-08-17T10:00:01.000000000Z", Pod: types.PodInfo{Name: "app-a", ID: "uid-a"}, Container: types.DefaultContainerName, Message: "started"},
+		{DateTime: "2026-08-17T10:00:02.000000000Z", Pod: types.PodInfo{Name: "app-a", ID: "uid-a"}, Container: "istio-proxy", Level: "error", Message: "upstream \"reset\", retrying\n\tattempt 2"},
+	},
+	NextPageToken: "token",
+	TimedOut:      []types.PodInfo{{Name: "app-b", ID: "uid-b"}},
+}
+
+// Text and CSV leave stdout to entries, so the token has to go somewhere else.
+func TestWritePageLeavesAllButEntriesToStderr(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if err := WritePage(&stdout, &stderr, page, Text, false); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "2026-08-17T10:00:01.000000000Z app-a started\n" +
+		"2026-08-17T10:00:02.000000000Z app-a/istio-proxy upstream \"reset\", retrying\n\tattempt 2\n"
+	if stdout.String() != want {
+		t.Errorf("expected %q, got %q", want, stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "Next page token: token") || !strings.Contains(stderr.String(), "app-b timed out") {
+		t.Errorf("expected the token and the timeout on stderr, got %q", stderr.String())
+	}
+}
+
+// Messages with commas, quotes and line breaks stay one field.
+func TestCSVQuotesMessages(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if err := WritePage(&stdout, &stderr, page, CSV, false); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&stdout).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not CSV: %v", err)
+	}
+	if len(rows) != 3 || rows[0][0] != "datetime" {
+		t.Fatalf("expected a header and two rows, got %q", rows)
+	}
+	if got := rows[2][len(rows[2])-1]; got != page.Results[1].Message {
+		t.Errorf("expected the message back whole, got %q", got)
+	}
+	if rows[2][4] != "istio-proxy" || rows[2][6] != "error" {
+		t.Errorf("expected the container and level columns, got %q", rows[2])
+	}
+}
+
+func TestTextColorsOnlyWhenAsked(t *testing.T) {
+	var plain, colored bytes.Buffer
+	NewEntryWriter(&plain, Text, false).Write(page.Results[1])
+	NewEntryWriter(&colored, Text, true).Write(page.Results[1])
+
+	if strings.Contains(plain.String(), "\033[") {
+		t.Errorf("expected no escapes without color, got %q", plain.String())
+	}
+	if !strings.Contains(colored.String(), red+page.Results[1].Message+reset) {
+		t.Errorf("expected the error in red, got %q", colored.String())
+	}
+}
+
+// Auto never colors what is not a terminal, such as a pipe or a buffer.
+func TestUseColor(t *testing.T) {
+	var buffer bytes.Buffer
+	if UseColor(ColorAuto, &buffer) || UseColor(ColorNever, &buffer) || !UseColor(ColorAlways, &buffer) {
+		t.Error("expected only always to color a buffer")
+	}
+}
@@ -0,0 +1,72 @@
+package pagination
+
+import (
+	"cmp"
+	"strconv"
+	"strings"
+
+	"kube-logger-go/internal/types"
+)
+
+// Position is where a line sits in its stream: its timestamp, and how many lines before it
+// in the stream share that timestamp. Bursts write several lines within the same nanosecond,
+// so the timestamp alone cannot tell which of them a page stopped at.
+type Position struct {
+	Time string
+	Seq  int
+}
+
+// ParsePosition reads a cursor written by Position.String. An empty cursor points nowhere.
+func ParsePosition(cursor string) Position {
+	if cursor == "" {
+		return Position{}
+	}
+
+	timestamp, seq, found := strings.Cut(cursor, "#")
+	if !found {
+		return Position{Time: cursor}
+	}
+
+	n, err := strconv.Atoi(seq)
+	if err != nil || n < 0 {
+		return Position{Time: timestamp}
+	}
+	return Position{Time: timestamp, Seq: n}
+}
+
+// String writes the position as a cursor, leaving out the sequence of the first line of a
+// timestamp so most cursors stay plain timestamps
+func (p Position) String() string {
+	if p.Seq == 0 {
+		return p.Time
+	}
+	return p.Time + "#" + strconv.Itoa(p.Seq)
+}
+
+// IsZero reports whether the position points nowhere
+func (p Position) IsZero() bool {
+	return p.Time == ""
+}
+
+// Compare orders two positions of the same stream, returning -1, 0 or +1. Timestamps from the
+// kubelet share one fixed-width format, so they order as strings.
+func (p Position) Compare(other Position) int {
+	if c := strings.Compare(p.Time, other.Time); c != 0 {
+		return c
+	}
+	return cmp.Compare(p.Seq, other.Seq)
+}
+
+// FirstLine returns the position of the first line of an entry
+func FirstLine(entry types.LogEntry) Position {
+	return Position{Time: entry.DateTime, Seq: entry.Seq}
+}
+
+// LastLine returns the position of the last line of an entry, which is where its stream
+// resumes. Only entries joined from several lines end after they start.
+func LastLine(entry types.LogEntry) Position {
+	if entry.LastDateTime != "" {
+		return Position{Time: entry.LastDateTime, Seq: entry.LastSeq}
+	}
+	return FirstLine(entry)
+}
@@ -1,39 +1,107 @@
 package pagination
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"slices"
 	"sort"
+	"strconv"
 
 	"kube-logger-go/internal/types"
 )
 
-// DecodeToken decodes a base64 pagination token into a map
-func DecodeToken(token string) map[string]string {
+// tokenVersion is the version of the token envelope this build writes and reads
+const tokenVersion = 1
+
+// envelope is what a token carries: the cursors, the query they belong to and a signature
+// over both
+type envelope struct {
+	Version   int               `json:"v"`
+	Query     string            `json:"q"`
+	Cursors   map[string]string `json:"c"`
+	Signature string            `json:"s,omitempty"`
+}
+
+// Codec writes and checks the tokens of one query. A token is only accepted by the query
+// that issued it, so changing the namespace, filter or window fails instead of resuming
+// cursors that mean nothing to the new query.
+type Codec struct {
+	key         []byte
+	fingerprint string
+}
+
+// NewCodec creates the codec of a query. Tokens are signed with key; without one the
+// signature still catches corrupted tokens, but anyone can forge it.
+func NewCodec(key string, config types.Config) *Codec {
+	return &Codec{key: []byte(key), fingerprint: Fingerprint(config)}
+}
+
+// Fingerprint identifies the lines a query reads. The limit and direction are left out, as
+// a page may be resized and the token keeps its own direction.
+func Fingerprint(config types.Config) string {
+	namespaces := slices.Clone(config.Namespaces)
+	slices.Sort(namespaces)
+	containers := slices.Clone(config.Containers)
+	slices.Sort(containers)
+
+	fields := []any{
+		config.AllNamespaces, namespaces,
+		config.ApplicationID, config.ScopeID, config.DeploymentID, config.InstanceID, containers, config.Previous, config.FilterPattern, config.IgnoreCase,
+		config.StartTime, config.EndTime, config.Multiline, config.MultilineStart, config.Archive,
+		config.Redact, config.RedactRules, config.JobName, config.ExecutionID, config.IncludeEvents,
+	}
+
+	query, _ := json.Marshal(fields)
+	sum := sha256.Sum256(query)
+	return hex.EncodeToString(sum[:16])
+}
+
+// Decode checks a token and returns its cursors. An empty token starts from the beginning
+// of the window; any other token that was not issued by this query is an error.
+func (c *Codec) Decode(token string) (map[string]string, error) {
 	if token == "" {
-		return make(map[string]string)
+		return make(map[string]string), nil
 	}
 
 	decoded, err := base64.StdEncoding.DecodeString(token)
 	if err != nil {
-		return make(map[string]string)
+		return nil, fmt.Errorf("token is not valid base64")
 	}
 
-	var result map[string]string
-	if err := json.Unmarshal(decoded, &result); err != nil {
-		return make(map[string]string)
+	var env envelope
+	if err := json.Unmarshal(decoded, &env); err != nil || env.Version == 0 {
+		return nil, fmt.Errorf("token is not a pagination token")
+	}
+	if env.Version != tokenVersion {
+		return nil, fmt.Errorf("token version %d is not supported", env.Version)
+	}
+	if !hmac.Equal([]byte(env.Signature), []byte(c.sign(env))) {
+		return nil, fmt.Errorf("token signature does not match")
+	}
+	if env.Query != c.fingerprint {
+		return nil, fmt.Errorf("token belongs to a different query")
 	}
 
-	return result
+	if env.Cursors == nil {
+		env.Cursors = make(map[string]string)
+	}
+	return env.Cursors, nil
 }
 
-// encodeToken encodes a map into a base64 pagination token
-func encodeToken(data map[string]string) string {
-	if len(data) == 0 {
+// Encode writes cursors into a signed token
+func (c *Codec) Encode(cursors map[string]string) string {
+	if len(cursors) == 0 {
 		return ""
 	}
 
-	jsonData, err := json.Marshal(data)
+	env := envelope{Version: tokenVersion, Query: c.fingerprint, Cursors: cursors}
+	env.Signature = c.sign(env)
+
+	jsonData, err := json.Marshal(env)
 	if err != nil {
 		return ""
 	}
@@ -41,11 +109,65 @@ func encodeToken(data map[string]string) string {
 	return base64.StdEncoding.EncodeToString(jsonData)
 }
 
+// sign returns the signature of an envelope, computed over everything but the signature.
+// Maps marshal with sorted keys, so the same cursors always sign the same.
+func (c *Codec) sign(env envelope) string {
+	env.Signature = ""
+	payload, _ := json.Marshal(env)
+
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CursorKey identifies the stream a cursor belongs to. The first instance of the default
+// container is keyed by the bare pod UID, which keeps tokens short in the common case. A
+// restart starts a new stream, and so a new cursor. The namespace leads the key of a pod
+// that names it, when the query spans namespaces.
+func CursorKey(pod types.PodInfo, container string, restart int) string {
+	key := pod.ID
+	if pod.Namespace != "" {
+		key = pod.Namespace + "/" + key
+	}
+	if container != "" && container != types.DefaultContainerName {
+		key += "/" + container
+	}
+	if restart > 0 {
+		key += "@" + strconv.Itoa(restart)
+	}
+	return key
+}
+
+// directionKey marks a token that pages backward. Cursor keys start with a pod UID or a
+// namespace followed by a slash, so it cannot collide with one. Forward tokens leave it out.
+const directionKey = "direction"
+
+// TokenDirection returns the direction a token pages in, so later pages keep the direction
+// of the first one without being told again. A query without a token pages as requested.
+func TokenDirection(cursors map[string]string, requested string) string {
+	if len(cursors) == 0 {
+		return requested
+	}
+	if cursors[directionKey] == types.DirectionBackward {
+		return types.DirectionBackward
+	}
+	return types.DirectionForward
+}
+
 // Page orders the entries, cuts them to the limit and returns the token that resumes after
-// the cut. The token records the newest entry kept per pod, so the cut keeps the oldest.
-func Page(entries []types.LogEntry, limit int, previous map[string]string) ([]types.LogEntry, string) {
-	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].DateTime < entries[j].DateTime
+// the cut. Paging forward, the cut keeps the oldest entries and the token records the newest
+// entry kept per stream. Paging backward it is the other way around: the newest entries come
+// first and the token records the oldest one kept.
+func Page(entries []types.LogEntry, limit int, previous map[string]string, direction string, codec *Codec) ([]types.LogEntry, string) {
+	// Lines of a stream sharing a timestamp stay in stream order, so a cut between them
+	// leaves a cursor that resumes at the next one.
+	backward := direction == types.DirectionBackward
+	sort.SliceStable(entries, func(i, j int) bool {
+		order := FirstLine(entries[i]).Compare(FirstLine(entries[j]))
+		if backward {
+			return order > 0
+		}
+		return order < 0
 	})
 
 	if len(entries) > limit {
@@ -55,24 +177,34 @@ func Page(entries []types.LogEntry, limit int, previous map[string]string) ([]ty
 		entries = []types.LogEntry{}
 	}
 
-	return entries, GenerateToken(entries, previous)
+	return entries, GenerateToken(entries, previous, direction, codec)
 }
 
-// GenerateToken creates a pagination token from log entries. previous keeps the cursor of a
-// pod that contributed nothing to this page, so the next page resumes it instead of reading
-// it again from start_time. An empty page returns an empty token, which ends pagination.
-func GenerateToken(logs []types.LogEntry, previous map[string]string) string {
+// GenerateToken creates a pagination token from log entries in page order. previous keeps the
+// cursor of a pod that contributed nothing to this page, so the next page resumes it instead
+// of reading it again from the start of the window. An empty page returns an empty token,
+// which ends pagination.
+func GenerateToken(logs []types.LogEntry, previous map[string]string, direction string, codec *Codec) string {
 	if len(logs) == 0 {
 		return ""
 	}
 
-	tokenData := make(map[string]string, len(previous)+len(logs))
+	tokenData := make(map[string]string, len(previous)+len(logs)+1)
 	for podID, lastRead := range previous {
 		tokenData[podID] = lastRead
 	}
 	for _, entry := range logs {
-		tokenData[entry.Pod.ID] = entry.DateTime
+		key := CursorKey(entry.Pod, entry.Container, entry.Restart)
+		if direction == types.DirectionBackward {
+			// A backward page resumes before the first line of the oldest entry it kept.
+			tokenData[key] = FirstLine(entry).String()
+		} else {
+			tokenData[key] = LastLine(entry).String()
+		}
+	}
+	if direction == types.DirectionBackward {
+		tokenData[directionKey] = types.DirectionBackward
 	}
 
-	return encodeToken(tokenData)
+	return codec.Encode(tokenData)
 }
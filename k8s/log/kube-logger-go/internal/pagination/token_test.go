@@ -1,11 +1,26 @@
 package pagination
 
 import (
+	"bytes"
+	"encoding/base64"
 	"testing"
 
 	"kube-logger-go/internal/types"
 )
 
+var testCodec = NewCodec("secret", types.Config{Namespaces: []string{"ns"}, ApplicationID: "1", ScopeID: "2"})
+
+// decode decodes a token the test expects to be valid
+func decode(t *testing.T, token string) map[string]string {
+	t.Helper()
+
+	cursors, err := testCodec.Decode(token)
+	if err != nil {
+		t.Fatalf("token %q rejected: %v", token, err)
+	}
+	return cursors
+}
+
 func entry(timestamp, podID string) types.LogEntry {
 	return types.LogEntry{
 		Message:  "line at " + timestamp,
@@ -23,7 +38,7 @@ func TestPageKeepsTheOldestEntriesAndTokensTheCut(t *testing.T) {
 		entry("2026-08-17T10:00:02Z", "b"),
 	}
 
-	page, token := Page(entries, 2, map[string]string{})
+	page, token := Page(entries, 2, map[string]string{}, types.DirectionForward, testCodec)
 
 	if len(page) != 2 {
 		t.Fatalf("expected the page to be cut to the limit, got %d entries", len(page))
@@ -32,7 +47,7 @@ func TestPageKeepsTheOldestEntriesAndTokensTheCut(t *testing.T) {
 		t.Errorf("expected the two oldest entries, got %s and %s", page[0].DateTime, page[1].DateTime)
 	}
 
-	cursors := DecodeToken(token)
+	cursors := decode(t, token)
 	if cursors["a"] != "2026-08-17T10:00:01Z" || cursors["b"] != "2026-08-17T10:00:02Z" {
 		t.Errorf("token does not point at the cut: %v", cursors)
 	}
@@ -45,9 +60,9 @@ func TestPageCarriesForwardPodsThatContributedNothing(t *testing.T) {
 		"b": "2026-08-17T10:00:02Z",
 	}
 
-	_, token := Page([]types.LogEntry{entry("2026-08-17T10:00:05Z", "a")}, 100, incoming)
+	_, token := Page([]types.LogEntry{entry("2026-08-17T10:00:05Z", "a")}, 100, incoming, types.DirectionForward, testCodec)
 
-	cursors := DecodeToken(token)
+	cursors := decode(t, token)
 	if cursors["a"] != "2026-08-17T10:00:05Z" {
 		t.Errorf("expected pod a to advance to its newest kept entry, got %q", cursors["a"])
 	}
@@ -58,7 +73,7 @@ func TestPageCarriesForwardPodsThatContributedNothing(t *testing.T) {
 
 // An empty page ends pagination, so the cursors must not survive it.
 func TestPageWithNoEntriesEndsPagination(t *testing.T) {
-	page, token := Page(nil, 100, map[string]string{"a": "2026-08-17T10:00:01Z"})
+	page, token := Page(nil, 100, map[string]string{"a": "2026-08-17T10:00:01Z"}, types.DirectionForward, testCodec)
 
 	if page == nil {
 		t.Error("expected an empty slice rather than nil, it is serialized as results")
@@ -71,10 +86,44 @@ func TestPageWithNoEntriesEndsPagination(t *testing.T) {
 	}
 }
 
+// Containers of one pod are read as separate streams, so each needs its own cursor.
+func TestPageKeepsACursorPerContainer(t *testing.T) {
+	sidecar := entry("2026-08-17T10:00:02Z", "a")
+	sidecar.Container = "istio-proxy"
+	entries := []types.LogEntry{entry("2026-08-17T10:00:01Z", "a"), sidecar}
+
+	_, token := Page(entries, 100, map[string]string{}, types.DirectionForward, testCodec)
+
+	cursors := decode(t, token)
+	if cursors["a"] != "2026-08-17T10:00:01Z" {
+		t.Errorf("expected the default container to keep the bare pod cursor, got %q", cursors["a"])
+	}
+	if cursors[CursorKey(types.PodInfo{ID: "a"}, "istio-proxy", 0)] != "2026-08-17T10:00:02Z" {
+		t.Errorf("expected the sidecar to have its own cursor, got %v", cursors)
+	}
+}
+
+// After a restart the container writes a new log, so the old cursor must not apply to it.
+func TestPageKeepsACursorPerRestart(t *testing.T) {
+	crashed := entry("2026-08-17T10:00:01Z", "a")
+	restarted := entry("2026-08-17T10:00:02Z", "a")
+	restarted.Restart = 1
+
+	_, token := Page([]types.LogEntry{crashed, restarted}, 100, map[string]string{}, types.DirectionForward, testCodec)
+
+	cursors := decode(t, token)
+	if cursors["a"] != "2026-08-17T10:00:01Z" {
+		t.Errorf("expected the first instance to keep the bare pod cursor, got %q", cursors["a"])
+	}
+	if cursors[CursorKey(types.PodInfo{ID: "a"}, types.DefaultContainerName, 1)] != "2026-08-17T10:00:02Z" {
+		t.Errorf("expected the restarted instance to have its own cursor, got %v", cursors)
+	}
+}
+
 func TestTokenRoundTrip(t *testing.T) {
 	cursors := map[string]string{"a": "2026-08-17T10:00:01Z", "b": "2026-08-17T10:00:02.5Z"}
 
-	decoded := DecodeToken(encodeToken(cursors))
+	decoded := decode(t, testCodec.Encode(cursors))
 
 	for podID, want := range cursors {
 		if decoded[podID] != want {
@@ -83,10 +132,135 @@ func TestTokenRoundTrip(t *testing.T) {
 	}
 }
 
-func TestDecodeTokenToleratesGarbage(t *testing.T) {
-	for _, token := range []string{"", "not base64!", "bm90IGpzb24="} {
-		if cursors := DecodeToken(token); len(cursors) != 0 {
-			t.Errorf("token %q: expected no cursors, got %v", token, cursors)
+// A token that cannot be trusted would restart the query from start_time without a word, so
+// it is refused instead.
+func TestDecodeRejectsTokensItDidNotIssue(t *testing.T) {
+	valid := testCodec.Encode(map[string]string{"a": "2026-08-17T10:00:01Z"})
+	raw, _ := base64.StdEncoding.DecodeString(valid)
+
+	legacy := base64.StdEncoding.EncodeToString([]byte(`{"a":"2026-08-17T10:00:01Z"}`))
+	tampered := base64.StdEncoding.EncodeToString(bytes.Replace(raw, []byte("10:00:01"), []byte("09:00:01"), 1))
+	future := base64.StdEncoding.EncodeToString(bytes.Replace(raw, []byte(`"v":1`), []byte(`"v":2`), 1))
+	otherKey := NewCodec("other", types.Config{Namespaces: []string{"ns"}, ApplicationID: "1", ScopeID: "2"}).Encode(map[string]string{"a": "x"})
+	otherQuery := NewCodec("secret", types.Config{Namespaces: []string{"ns"}, ApplicationID: "1", ScopeID: "3"}).Encode(map[string]string{"a": "x"})
+
+	for name, token := range map[string]string{
+		"garbage":     "not base64!",
+		"not json":    "bm90IGpzb24=",
+		"legacy":      legacy,
+		"tampered":    tampered,
+		"version":     future,
+		"other key":   otherKey,
+		"other query": otherQuery,
+	} {
+		if cursors, err := testCodec.Decode(token); err == nil {
+			t.Errorf("%s: expected the token to be rejected, got %v", name, cursors)
+		}
+	}
+
+	if cursors := decode(t, ""); len(cursors) != 0 {
+		t.Errorf("expected no token to start from the beginning, got %v", cursors)
+	}
+}
+
+// Resizing pages or keeping the direction from the token must not invalidate it.
+func TestFingerprintIgnoresLimitAndDirection(t *testing.T) {
+	config := types.Config{Namespaces: []string{"ns"}, Containers: []string{"b", "a"}, Limit: 10}
+	resized := types.Config{Namespaces: []string{"ns"}, Containers: []string{"a", "b"}, Limit: 50, Direction: types.DirectionBackward}
+
+	if Fingerprint(config) != Fingerprint(resized) {
+		t.Error("expected the same query to keep its fingerprint")
+	}
+	if Fingerprint(config) == Fingerprint(types.Config{Namespaces: []string{"ns"}, Containers: []string{"a", "b"}, FilterPattern: "error"}) {
+		t.Error("expected a different filter to change the fingerprint")
+	}
+}
+
+// The newest entries come first, and the token resumes before the oldest one kept.
+func TestPageBackwardKeepsTheNewestEntriesAndMarksTheToken(t *testing.T) {
+	entries := []types.LogEntry{
+		entry("2026-08-17T10:00:03Z", "a"),
+		entry("2026-08-17T10:00:01Z", "a"),
+		entry("2026-08-17T10:00:04Z", "b"),
+		entry("2026-08-17T10:00:02Z", "b"),
+	}
+
+	page, token := Page(entries, 3, map[string]string{}, types.DirectionBackward, testCodec)
+
+	if len(page) != 3 || page[0].DateTime != "2026-08-17T10:00:04Z" || page[2].DateTime != "2026-08-17T10:00:02Z" {
+		t.Fatalf("expected the three newest entries, newest first, got %v", page)
+	}
+
+	cursors := decode(t, token)
+	if cursors["a"] != "2026-08-17T10:00:03Z" || cursors["b"] != "2026-08-17T10:00:02Z" {
+		t.Errorf("token does not point at the cut: %v", cursors)
+	}
+	if TokenDirection(cursors, types.DirectionForward) != types.DirectionBackward {
+		t.Errorf("expected the token to keep paging backward, got %v", cursors)
+	}
+}
+
+// Only backward tokens carry a direction, so a token without one pages forward.
+func TestTokenDirectionDefaultsToForward(t *testing.T) {
+	if got := TokenDirection(map[string]string{"a": "2026-08-17T10:00:01Z"}, types.DirectionBackward); got != types.DirectionForward {
+		t.Errorf("expected a token without a direction to page forward, got %q", got)
+	}
+	if got := TokenDirection(map[string]string{}, types.DirectionBackward); got != types.DirectionBackward {
+		t.Errorf("expected a fresh query to page as requested, got %q", got)
+	}
+}
+
+// Cursors name the line within a burst, and the first line of a timestamp is the plain timestamp.
+func TestParsePositionRoundTrips(t *testing.T) {
+	for _, position := range []Position{
+		{Time: "2026-08-17T10:00:01.000000000Z"},
+		{Time: "2026-08-17T10:00:01.000000000Z", Seq: 3},
+	} {
+		if got := ParsePosition(position.String()); got != position {
+			t.Errorf("expected %v back from %q, got %v", position, position.String(), got)
 		}
 	}
+
+	if !ParsePosition("").IsZero() {
+		t.Error("expected an empty cursor to point nowhere")
+	}
+}
+
+// A page cut inside a burst leaves a cursor on the last line of the burst it kept.
+func TestPageCutsBetweenLinesSharingATimestamp(t *testing.T) {
+	var entries []types.LogEntry
+	for seq := range 3 {
+		burst := entry("2026-08-17T10:00:01Z", "a")
+		burst.Seq = seq
+		entries = append(entries, burst)
+	}
+
+	_, token := Page(entries, 2, map[string]string{}, types.DirectionForward, testCodec)
+
+	if cursor := decode(t, token)["a"]; cursor != "2026-08-17T10:00:01Z#1" {
+		t.Errorf("expected the cursor on the second line of the burst, got %q", cursor)
+	}
+}
+
+// Pod UIDs only identify a pod within the namespaces a query reads when they are named.
+func TestCursorKeyNamesTheNamespaceOfPodsThatCarryIt(t *testing.T) {
+	if key := CursorKey(types.PodInfo{ID: "a", Namespace: "ns"}, "istio-proxy", 1); key != "ns/a/istio-proxy@1" {
+		t.Errorf("expected the namespace to lead the key, got %q", key)
+	}
+	if key := CursorKey(types.PodInfo{ID: "a"}, types.DefaultContainerName, 0); key != "a" {
+		t.Errorf("expected a pod without a namespace to keep the bare UID, got %q", key)
+	}
+}
+
+// Listing the same namespaces in another order is the same query.
+func TestFingerprintOfNamespaces(t *testing.T) {
+	ab := types.Config{Namespaces: []string{"a", "b"}}
+	ba := types.Config{Namespaces: []string{"b", "a"}}
+
+	if Fingerprint(ab) != Fingerprint(ba) {
+		t.Error("expected the order of namespaces not to matter")
+	}
+	if Fingerprint(ab) == Fingerprint(types.Config{AllNamespaces: true}) || Fingerprint(ab) == Fingerprint(types.Config{Namespaces: []string{"a"}}) {
+		t.Error("expected other namespaces to change the fingerprint")
+	}
 }
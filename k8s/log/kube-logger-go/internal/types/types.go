@@ -1,40 +1,237 @@
 package types
 
+import "time"
+
 const (
 	DefaultContainerName = "application"
-	DefaultLimit        = 100
-	MinLogsPerPod       = 10
+	AllContainers        = "all"
+	DefaultLimit         = 100
+	MaxServedLimit       = 10000
+	MinLogsPerPod        = 10
+	DefaultIdleTimeout   = 5 * time.Minute
+	DefaultParallelism   = 10
+	DefaultPodTimeout    = 10 * time.Second
+	DefaultTimeout       = 30 * time.Second
+	MaxHistogramBuckets  = 10000
+	DefaultCacheTTL      = time.Hour
+	DefaultCacheMaxBytes = 256 << 20
+	DefaultListen        = "127.0.0.1:8080"
+)
+
+// Paging directions. Backward starts at the end of the window and pages into the past.
+const (
+	DirectionForward  = "forward"
+	DirectionBackward = "backward"
+)
+
+// Previous-instance modes. Kubernetes keeps the log of the instance that ran before a
+// container's last restart, which is where the reason for a crash loop usually is.
+const (
+	PreviousNever  = ""
+	PreviousAuto   = "auto"
+	PreviousAlways = "always"
+)
+
+// Labels the Job controller puts on the pods it creates
+const (
+	JobNameLabel = "job-name"
+	JobUIDLabel  = "controller-uid"
+)
+
+// Histogram groupings. A histogram split by level counts entries without one as unknown.
+const (
+	HistogramByPod   = "pod"
+	HistogramByLevel = "level"
+	UnknownLevel     = "unknown"
+)
+
+// Kubernetes events of a pod are read as a stream of their own, in the pseudo container
+// EventsContainer, and their entries come from SourceEvent. Its name is not a valid container
+// name, so it cannot clash with a real one.
+const (
+	EventsContainer = "_events"
+	SourceEvent     = "event"
 )
 
-// LogEntry represents a single log entry
+// LogEntry represents a single log entry. Restart is the generation of the container instance
+// that wrote it, so lines from before and after a restart can be told apart. Messages written
+// as JSON or logfmt also carry their parsed fields, with the level, logger and trace id
+// normalized whatever key the application used for them.
+//
+// Seq counts the earlier lines of the stream that share the timestamp, which tells apart
+// lines written in the same nanosecond. LastDateTime and LastSeq are those of the last line
+// of an entry joined from several lines, and Redactions counts what was redacted from its
+// message. None of them is part of the output.
 type LogEntry struct {
-	Message  string  `json:"message"`
-	DateTime string  `json:"datetime"`
-	Pod      PodInfo `json:"pod"`
+	Message   string         `json:"message"`
+	DateTime  string         `json:"datetime"`
+	Pod       PodInfo        `json:"pod"`
+	Container string         `json:"container"`
+	Restart   int            `json:"restart"`
+	Level     string         `json:"level,omitempty"`
+	Logger    string         `json:"logger,omitempty"`
+	TraceID   string         `json:"trace_id,omitempty"`
+	Fields    map[string]any `json:"fields,omitempty"`
+	Metadata  *Metadata      `json:"metadata,omitempty"`
+	Source    string         `json:"source,omitempty"`
+
+	Seq          int    `json:"-"`
+	LastDateTime string `json:"-"`
+	LastSeq      int    `json:"-"`
+	Redactions   int    `json:"-"`
 }
 
-// PodInfo contains pod identification information
+// Metadata tells where an entry was written beyond its pod: the deployment and scope the pod
+// belongs to, the node it runs on, the image tag of the container and how often the container
+// has restarted. Pods read from an archive only know their labels and restarts.
+type Metadata struct {
+	DeploymentID string `json:"deployment_id,omitempty"`
+	ScopeID      string `json:"scope_id,omitempty"`
+	JobName      string `json:"job_name,omitempty"`
+	Node         string `json:"node,omitempty"`
+	ImageTag     string `json:"image_tag,omitempty"`
+	RestartCount int    `json:"restart_count"`
+}
+
+// PodInfo contains pod identification information. Namespace is only set when the query
+// spans namespaces; otherwise it is the one asked for.
 type PodInfo struct {
-	Name string `json:"name"`
-	ID   string `json:"id"`
+	Name      string `json:"name"`
+	ID        string `json:"id"`
+	Namespace string `json:"namespace,omitempty"`
 }
 
 // Response is the final output structure
 type Response struct {
 	Results       []LogEntry `json:"results"`
 	NextPageToken string     `json:"next_page_token"`
+	TimedOut      []PodInfo  `json:"timed_out,omitempty"`
+	Warnings      []Warning  `json:"warnings,omitempty"`
+	// Redactions counts the secrets and personal data redacted from the entries of the page
+	Redactions int `json:"redactions,omitempty"`
+}
+
+// Warning reports a container log that could not be read, so a page without its lines is not
+// mistaken for a container that logged nothing. Reason is the reason the Kubernetes API gave,
+// such as Forbidden or BadRequest for a container that has not started.
+type Warning struct {
+	Pod       PodInfo `json:"pod"`
+	Container string  `json:"container"`
+	Restart   int     `json:"restart"`
+	Reason    string  `json:"reason"`
+	Message   string  `json:"message"`
+}
+
+// Bucket counts the entries written from Start for the length of a bucket. Groups splits
+// Count by pod or level when asked to.
+type Bucket struct {
+	Start  string         `json:"start"`
+	Count  int            `json:"count"`
+	Groups map[string]int `json:"groups,omitempty"`
+}
+
+// HistogramResponse is the output of a histogram, in place of a page of entries
+type HistogramResponse struct {
+	Buckets  []Bucket  `json:"buckets"`
+	TimedOut []PodInfo `json:"timed_out,omitempty"`
+	Warnings []Warning `json:"warnings,omitempty"`
+}
+
+// ErrorResponse is what the server answers a query it cannot serve with
+type ErrorResponse struct {
+	Error string `json:"error"`
 }
 
 // Config holds all command line configuration
 type Config struct {
-	Namespace      string
-	ApplicationID  string
-	ScopeID        string
-	DeploymentID   string
-	Limit          int
-	NextPageToken  string
-	FilterPattern  string
-	StartTime      string
-	EndTime        string
-	InstanceID     string
-}
\ No newline at end of file
+	Namespaces    []string
+	AllNamespaces bool
+	ApplicationID string
+	ScopeID       string
+	DeploymentID  string
+	Limit         int
+	NextPageToken string
+	FilterPattern string
+	IgnoreCase    bool
+	StartTime     string
+	EndTime       string
+	InstanceID    string
+	JobName       string
+	ExecutionID   string
+	Direction     string
+	Containers    []string
+	Previous      string
+	Follow        bool
+	IdleTimeout   time.Duration
+
+	Multiline      bool
+	MultilineStart string
+
+	// Parallelism caps the streams read at once; PodTimeout bounds each and Timeout the
+	// whole request
+	Parallelism int
+	PodTimeout  time.Duration
+	Timeout     time.Duration
+
+	// Histogram counts the entries of the window per bucket of this length instead of
+	// returning them, split by HistogramBy if set
+	Histogram   time.Duration
+	HistogramBy string
+
+	// Redact replaces secrets and personal data in messages, with the rules of RedactRules
+	// too if set
+	Redact      bool
+	RedactRules string
+
+	// Output is the format the response is written in, and Color whether text is colored
+	Output string
+	Color  string
+
+	// IncludeMetadata attaches the Metadata of their container to entries
+	IncludeMetadata bool
+	// IncludeEvents interleaves the Kubernetes events of the pods with their logs
+	IncludeEvents bool
+
+	// Strict fails the request when no container log could be read
+	Strict bool
+
+	// Archive is where logs of pods that no longer run are read from, if anywhere
+	Archive string
+
+	// CacheDir keeps reads that can no longer change, for CacheTTL and up to CacheMaxBytes in
+	// all, if set
+	CacheDir      string
+	CacheTTL      time.Duration
+	CacheMaxBytes int64
+
+	// Verbose reports how the request went, such as its cache hits, to stderr
+	Verbose bool
+
+	// Serve answers queries over HTTP on Listen instead of answering one, with the rest of
+	// the configuration as the defaults of every query. It does not authenticate queries, so
+	// it only answers for its own namespaces and listens on loopback unless told otherwise.
+	// Server sends the query to such a server instead of reading the cluster.
+	Serve  bool
+	Listen string
+	Server string
+
+	// Kubeconfig and Context choose the cluster; without either the in-cluster config is
+	// tried first. As and AsGroups impersonate a user, and QPS and Burst rate-limit requests
+	// to the API server, zero keeping the client defaults.
+	Kubeconfig string
+	Context    string
+	As         string
+	AsGroups   []string
+	QPS        float64
+	Burst      int
+
+	// TokenKey signs pagination tokens. It comes from the environment, not a flag, so it does
+	// not show in the process list.
+	TokenKey string
+}
+
+// SpansNamespaces reports whether a query reads more than one namespace, in which case pods
+// are told apart by their namespace as well
+func (c Config) SpansNamespaces() bool {
+	return c.AllNamespaces || len(c.Namespaces) > 1
+}
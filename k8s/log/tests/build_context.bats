@@ -62,6 +62,16 @@ full_context() {
   assert_equal "$output" "100"
 }
 
+@test "build_context: extracts the execution of a scheduled task" {
+  local context='{"notification":{"arguments":{"application_id":"26611171","job_name":"job-1-2-29001","execution_id":"5d1c"}}}'
+
+  run extract "$context" JOB_NAME
+  assert_equal "$output" "job-1-2-29001"
+
+  run extract "$context" EXECUTION_ID
+  assert_equal "$output" "5d1c"
+}
+
 @test "build_context: fails when application_id is missing" {
   run bash -c "NP_ACTION_CONTEXT='{\"notification\":{\"arguments\":{}}}' source '$BUILD_CONTEXT'"
   [ "$status" -ne 0 ]
@@ -29,7 +29,7 @@ setup() {
 
 teardown() {
   unset -f epoch_ms_to_iso 2>/dev/null || true
-  unset SERVICE_PATH APPLICATION_ID SCOPE_ID START_TIME END_TIME 2>/dev/null || true
+  unset SERVICE_PATH APPLICATION_ID SCOPE_ID START_TIME END_TIME FILTER_PATTERN DIRECTION LOG_ARCHIVE LOG_CACHE_DIR KUBE_LOGGER_SERVER HISTOGRAM_BUCKET HISTOGRAM_BY INCLUDE_METADATA INCLUDE_EVENTS REDACT REDACT_RULES JOB_NAME EXECUTION_ID NAMESPACE_OVERRIDE ALL_NAMESPACES 2>/dev/null || true
   [ -n "$STUB_ROOT" ] && rm -rf "$STUB_ROOT"
 }
 
@@ -86,3 +86,121 @@ teardown() {
   [ "$status" -ne 0 ]
   [[ "$output" != *"--start-time"* ]]
 }
+
+@test "log: passes a filter with quoted phrases through unchanged" {
+  export FILTER_PATTERN='"connection refused" -healthcheck'
+
+  run bash "$LOG_SCRIPT"
+  [ "$status" -eq 0 ]
+  assert_contains "$output" '--filter "connection refused" -healthcheck'
+}
+
+@test "log: passes the paging direction when requested" {
+  export DIRECTION=backward
+
+  run bash "$LOG_SCRIPT"
+  [ "$status" -eq 0 ]
+  assert_contains "$output" "--direction backward"
+}
+
+@test "log: passes the archive of deleted pods when configured" {
+  export LOG_ARCHIVE=s3://logs-archive/pods
+
+  run bash "$LOG_SCRIPT"
+  [ "$status" -eq 0 ]
+  assert_contains "$output" "--archive s3://logs-archive/pods"
+}
+
+@test "log: passes the cache directory when configured" {
+  export LOG_CACHE_DIR=/var/cache/kube-logger
+
+  run bash "$LOG_SCRIPT"
+  [ "$status" -eq 0 ]
+  assert_contains "$output" "--cache-dir /var/cache/kube-logger"
+}
+
+@test "log: sends the query to a server when configured" {
+  export KUBE_LOGGER_SERVER=http://kube-logger.nullplatform:8080
+
+  run bash "$LOG_SCRIPT"
+  [ "$status" -eq 0 ]
+  assert_contains "$output" "--server http://kube-logger.nullplatform:8080"
+}
+
+@test "log: passes the histogram bucket and grouping when requested" {
+  export HISTOGRAM_BUCKET=1m
+  export HISTOGRAM_BY=level
+
+  run bash "$LOG_SCRIPT"
+  [ "$status" -eq 0 ]
+  assert_contains "$output" "--histogram 1m --histogram-by level"
+}
+
+@test "log: passes every namespace of the override" {
+  export NAMESPACE_OVERRIDE=team-a,team-b
+
+  run bash "$LOG_SCRIPT"
+  [ "$status" -eq 0 ]
+  assert_contains "$output" "--namespace team-a,team-b"
+}
+
+@test "log: searches every namespace instead of one when asked" {
+  export ALL_NAMESPACES=true
+
+  run bash "$LOG_SCRIPT"
+  [ "$status" -eq 0 ]
+  assert_contains "$output" "--all-namespaces --application-id"
+  [[ "$output" != *"--namespace"* ]]
+}
+
+@test "log: asks for entry metadata when enabled" {
+  export INCLUDE_METADATA=true
+
+  run bash "$LOG_SCRIPT"
+  [ "$status" -eq 0 ]
+  assert_contains "$output" "--include-metadata"
+}
+
+@test "log: asks for pod events when enabled" {
+  export INCLUDE_EVENTS=true
+
+  run bash "$LOG_SCRIPT"
+  [ "$status" -eq 0 ]
+  assert_contains "$output" "--include-events"
+}
+
+@test "log: passes the execution of a scheduled task" {
+  export JOB_NAME=job-1-2-29001
+  export EXECUTION_ID=5d1c
+
+  run bash "$LOG_SCRIPT"
+  [ "$status" -eq 0 ]
+  assert_contains "$output" "--job-name job-1-2-29001"
+  assert_contains "$output" "--execution-id 5d1c"
+}
+
+@test "log: passes the execution of a scheduled task without running it" {
+  export JOB_NAME='job; echo injected'
+  export EXECUTION_ID='$(echo injected)'
+
+  run bash "$LOG_SCRIPT"
+  [ "$status" -eq 0 ]
+  assert_contains "$output" '--job-name job; echo injected --execution-id $(echo injected)'
+  [ "$(grep -c injected <<< "$output")" -eq 1 ]
+}
+
+@test "log: asks for redaction when enabled" {
+  export REDACT=true
+
+  run bash "$LOG_SCRIPT"
+  [ "$status" -eq 0 ]
+  assert_contains "$output" "--redact"
+}
+
+@test "log: passes the redaction rules file" {
+  export REDACT_RULES=/etc/np/redact.json
+
+  run bash "$LOG_SCRIPT"
+  [ "$status" -eq 0 ]
+  assert_contains "$output" "--redact-rules /etc/np/redact.json"
+}
@@ -0,0 +1,49 @@
+#!/usr/bin/env bats
+# =============================================================================
+# Tests that the scheduled_task log workflow reads logs the way the k8s one does.
+#
+# Contract:
+#   - The context is built by the k8s build_context before the logs are read,
+#     since that is where the scope, window, token and the execution of the
+#     task (JOB_NAME / EXECUTION_ID) come from.
+#   - Both steps are the k8s scripts, so the two workflows cannot drift apart.
+# =============================================================================
+
+setup() {
+  export PROJECT_ROOT="$(cd "$BATS_TEST_DIRNAME/../../.." && pwd)"
+  source "$PROJECT_ROOT/testing/assertions.sh"
+
+  export WORKFLOW="$PROJECT_ROOT/scheduled_task/log/workflows/log.yaml"
+  export BASE="$PROJECT_ROOT/k8s/log/workflows/log.yaml"
+}
+
+@test "scheduled_task log workflow builds the context with the k8s build_context" {
+  run grep -A 4 "name: build context" "$WORKFLOW"
+
+  assert_equal "$status" "0"
+  assert_contains "$output" "type: script"
+  assert_contains "$output" '$SERVICE_PATH/log/build_context'
+  assert_contains "$output" 'K8S_NAMESPACE: $K8S_NAMESPACE'
+}
+
+@test "scheduled_task log workflow reads the logs with the k8s log script" {
+  run grep -A 2 "name: logs" "$WORKFLOW"
+
+  assert_equal "$status" "0"
+  assert_contains "$output" '$SERVICE_PATH/log/log'
+}
+
+@test "scheduled_task log workflow builds the context before reading the logs" {
+  run grep -n "name:" "$WORKFLOW"
+
+  assert_equal "$status" "0"
+  assert_equal "$output" "$(grep -n "name:" "$BASE")"
+}
+
+@test "k8s build_context exports the execution of a scheduled task" {
+  run grep -E "export (JOB_NAME|EXECUTION_ID)" "$PROJECT_ROOT/k8s/log/build_context"
+
+  assert_equal "$status" "0"
+  assert_contains "$output" "JOB_NAME"
+  assert_contains "$output" "EXECUTION_ID"
+}